@@ -0,0 +1,298 @@
+package core
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erfjab/egobot/models"
+)
+
+// DefaultAdminCacheTTL is how long a chat's administrator list is cached
+const DefaultAdminCacheTTL = 5 * time.Minute
+
+// ChatPermission is an administrator right, named after its models.ChatMember field
+type ChatPermission string
+
+const (
+	CanManageChat       ChatPermission = "can_manage_chat"
+	CanDeleteMessages   ChatPermission = "can_delete_messages"
+	CanManageVideoChats ChatPermission = "can_manage_video_chats"
+	CanRestrictMembers  ChatPermission = "can_restrict_members"
+	CanPromoteMembers   ChatPermission = "can_promote_members"
+	CanChangeInfo       ChatPermission = "can_change_info"
+	CanInviteUsers      ChatPermission = "can_invite_users"
+	CanPostMessages     ChatPermission = "can_post_messages"
+	CanEditMessages     ChatPermission = "can_edit_messages"
+	CanPinMessages      ChatPermission = "can_pin_messages"
+	CanManageTopics     ChatPermission = "can_manage_topics"
+)
+
+// Granted reports whether member has the permission. The chat creator has all permissions.
+func (p ChatPermission) Granted(member *models.ChatMember) bool {
+	if member == nil {
+		return false
+	}
+	switch member.Status {
+	case "creator":
+		return true
+	case "administrator":
+	default:
+		return false
+	}
+
+	switch p {
+	case CanManageChat:
+		return member.CanManageChat
+	case CanDeleteMessages:
+		return member.CanDeleteMessages
+	case CanManageVideoChats:
+		return member.CanManageVideoChats
+	case CanRestrictMembers:
+		return member.CanRestrictMembers
+	case CanPromoteMembers:
+		return member.CanPromoteMembers
+	case CanChangeInfo:
+		return member.CanChangeInfo
+	case CanInviteUsers:
+		return member.CanInviteUsers
+	case CanPostMessages:
+		return member.CanPostMessages
+	case CanEditMessages:
+		return member.CanEditMessages
+	case CanPinMessages:
+		return member.CanPinMessages
+	case CanManageTopics:
+		return member.CanManageTopics
+	}
+	return false
+}
+
+type adminCacheEntry struct {
+	admins  []models.ChatMember
+	expires time.Time
+}
+
+// AdminCache caches chat administrator lists for a TTL.
+// A chat's entry is dropped when a chat_member or my_chat_member update for it
+// is processed; add those to PollingOptions.AllowedUpdates to receive them.
+type AdminCache struct {
+	bot     *Bot
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[int64]adminCacheEntry
+	botID   int64
+
+	trustAnonymous bool
+}
+
+// NewAdminCache creates an admin cache. A non-positive ttl uses DefaultAdminCacheTTL.
+func NewAdminCache(bot *Bot, ttl time.Duration) *AdminCache {
+	if ttl <= 0 {
+		ttl = DefaultAdminCacheTTL
+	}
+	return &AdminCache{
+		bot:     bot,
+		ttl:     ttl,
+		entries: make(map[int64]adminCacheEntry),
+	}
+}
+
+// SetTTL changes how long administrator lists are cached
+func (c *AdminCache) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultAdminCacheTTL
+	}
+	c.mu.Lock()
+	c.ttl = ttl
+	c.mu.Unlock()
+}
+
+// TrustAnonymousAdmins makes HasPermissionFilter grant every permission to
+// messages sent anonymously on behalf of the group. Telegram doesn't tell
+// which administrator sent them, so by default they only pass IsChatAdminFilter.
+func (c *AdminCache) TrustAnonymousAdmins(trust bool) {
+	c.mu.Lock()
+	c.trustAnonymous = trust
+	c.mu.Unlock()
+}
+
+func (c *AdminCache) trustsAnonymous() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.trustAnonymous
+}
+
+// Admins returns the administrators of a chat, from cache when fresh
+func (c *AdminCache) Admins(chatID int64) ([]models.ChatMember, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[chatID]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.admins, nil
+	}
+
+	admins, err := c.bot.GetChatAdministrators(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[chatID] = adminCacheEntry{admins: admins, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return admins, nil
+}
+
+// Admin returns the administrator entry of a user in a chat, or nil if the user is not an administrator
+func (c *AdminCache) Admin(chatID, userID int64) (*models.ChatMember, error) {
+	admins, err := c.Admins(chatID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range admins {
+		if admins[i].User.ID == userID {
+			return &admins[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Invalidate drops the cached administrator list of a chat
+func (c *AdminCache) Invalidate(chatID int64) {
+	c.mu.Lock()
+	delete(c.entries, chatID)
+	c.mu.Unlock()
+}
+
+// InvalidateAll drops all cached administrator lists
+func (c *AdminCache) InvalidateAll() {
+	c.mu.Lock()
+	c.entries = make(map[int64]adminCacheEntry)
+	c.mu.Unlock()
+}
+
+// observe invalidates the chat of membership changes
+func (c *AdminCache) observe(update *models.Update) {
+	switch {
+	case update.ChatMember != nil:
+		c.Invalidate(update.ChatMember.Chat.ID)
+	case update.MyChatMember != nil:
+		c.Invalidate(update.MyChatMember.Chat.ID)
+	}
+}
+
+// BotID returns the bot's user ID, taken from the token or GetMe
+func (c *AdminCache) BotID() (int64, error) {
+	c.mu.Lock()
+	id := c.botID
+	c.mu.Unlock()
+	if id != 0 {
+		return id, nil
+	}
+
+	if prefix, _, ok := strings.Cut(c.bot.Token, ":"); ok {
+		id, _ = strconv.ParseInt(prefix, 10, 64)
+	}
+	if id == 0 {
+		me, err := c.bot.GetMe()
+		if err != nil {
+			return 0, err
+		}
+		id = me.ID
+	}
+
+	c.mu.Lock()
+	c.botID = id
+	c.mu.Unlock()
+	return id, nil
+}
+
+// updateAdmin resolves the administrator entry of the update's sender.
+// Messages sent anonymously on behalf of the group are from an unknown
+// administrator: anonymous is true and the entry holds no permissions.
+func updateAdmin(bot *Bot, update *models.Update) (member *models.ChatMember, anonymous bool) {
+	chat := update.EffectiveChat()
+	if chat == nil || chat.Type == "private" {
+		return nil, false
+	}
+	if sender := update.EffectiveSender(); sender.IsChat() {
+		if sender.Chat.ID == chat.ID {
+			return &models.ChatMember{Status: "administrator", IsAnonymous: true}, true
+		}
+		// Channels and linked chats are not members
+		return nil, false
+	}
+	user := update.EffectiveUser()
+	if user == nil {
+		return nil, false
+	}
+
+	member, err := bot.AdminCache.Admin(chat.ID, user.ID)
+	if err != nil {
+		log.Printf("Error getting chat administrators: %v", err)
+		return nil, false
+	}
+	return member, false
+}
+
+// IsChatAdminFilter filters updates sent by an administrator of the chat,
+// including messages sent anonymously on behalf of the group
+func IsChatAdminFilter(bot *Bot) FilterFunc {
+	return func(update *models.Update) bool {
+		member, _ := updateAdmin(bot, update)
+		return member != nil
+	}
+}
+
+// HasPermissionFilter filters updates sent by an administrator having all the given permissions.
+// Messages sent anonymously on behalf of the group are rejected unless
+// AdminCache.TrustAnonymousAdmins is set.
+func HasPermissionFilter(bot *Bot, permissions ...ChatPermission) FilterFunc {
+	return func(update *models.Update) bool {
+		member, anonymous := updateAdmin(bot, update)
+		if member == nil {
+			return false
+		}
+		if anonymous {
+			return bot.AdminCache.trustsAnonymous()
+		}
+		for _, permission := range permissions {
+			if !permission.Granted(member) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// IsBotAdminFilter filters updates in chats where the bot is an administrator
+// having all the given permissions
+func IsBotAdminFilter(bot *Bot, permissions ...ChatPermission) FilterFunc {
+	return func(update *models.Update) bool {
+		chat := update.EffectiveChat()
+		if chat == nil || chat.Type == "private" {
+			return false
+		}
+		botID, err := bot.AdminCache.BotID()
+		if err != nil {
+			log.Printf("Error getting bot ID: %v", err)
+			return false
+		}
+		member, err := bot.AdminCache.Admin(chat.ID, botID)
+		if err != nil {
+			log.Printf("Error getting chat administrators: %v", err)
+			return false
+		}
+		if member == nil {
+			return false
+		}
+		for _, permission := range permissions {
+			if !permission.Granted(member) {
+				return false
+			}
+		}
+		return true
+	}
+}
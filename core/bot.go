@@ -1,10 +1,12 @@
 package core
 
 import (
+	"context"
 	"log"
 	"time"
 
 	"github.com/erfjab/egobot/core/methods"
+	"github.com/erfjab/egobot/i18n"
 	"github.com/erfjab/egobot/models"
 	"github.com/erfjab/egobot/state"
 	"github.com/erfjab/egobot/state/storage"
@@ -16,6 +18,10 @@ type Bot struct {
 	handlers      *Handlers
 	errorHandlers *ErrorHandlers
 	StateManager  *state.Manager
+	AdminCache    *AdminCache
+	translator    *i18n.Translator
+	Services      *Services
+	commandSync   commandSync
 	*RegisterCommands
 }
 
@@ -27,16 +33,54 @@ func NewBot(token string) *Bot {
 		errorHandlers: NewErrorHandlers(),
 		StateManager:  state.NewManager(storage.NewMemoryStorage()),
 	}
+	bot.requester.ErrorFactory = newRequesterError
+	bot.AdminCache = NewAdminCache(bot, DefaultAdminCacheTTL)
+	bot.Services = NewServices()
 	bot.RegisterCommands = NewRegisterCommands(bot)
 	return bot
 }
 
 // SetStorage sets a custom storage backend for state management
 func (b *Bot) SetStorage(store storage.BaseStorage) {
-	b.StateManager = state.NewManager(store)
+	if b.translator != nil && b.StateManager != nil && b.translator.Storage() == b.StateManager.GetStorage() {
+		b.translator.SetStorage(store)
+	}
+	if b.StateManager == nil {
+		b.StateManager = state.NewManager(store)
+		return
+	}
+	b.StateManager.SetStorage(store)
+}
+
+// StateExpiredFunc handles a user whose state expired, e.g. to tell them
+// their session timed out
+type StateExpiredFunc func(bot *Bot, expired *state.Expired) error
+
+// OnStateExpired sets a handler run when a user's state and data expire.
+// Only MemoryStorage reports expirations (see state.Manager.OnStateExpired).
+// TTLs are set with state.WithTTL or StateManager.SetStateTTL:
+//
+//	bot.OnStateExpired(func(bot *core.Bot, expired *state.Expired) error {
+//		chatID, ok := expired.ChatID()
+//		if !ok {
+//			return nil
+//		}
+//		_, err := bot.SendMessage(&models.SendMessageParams{
+//			ChatID: chatID,
+//			Text:   "Your session timed out.",
+//		})
+//		return err
+//	})
+func (b *Bot) OnStateExpired(handler StateExpiredFunc) {
+	b.StateManager.OnStateExpired(func(ctx context.Context, expired *state.Expired) {
+		if err := handler(b, expired); err != nil {
+			log.Printf("Error handling expired state: %v", err)
+		}
+	})
 }
 
 // AddHandler adds a custom handler with a filter and optional state filter
+// (a *state.Filter, or a *state.StateGroup matching any of its states)
 func (b *Bot) AddHandler(filter FilterFunc, handler HandlerFunc, opts ...interface{}) {
 	var stateFilter *state.Filter
 	var middlewares []MiddlewareFunc
@@ -45,6 +89,8 @@ func (b *Bot) AddHandler(filter FilterFunc, handler HandlerFunc, opts ...interfa
 		switch v := opt.(type) {
 		case *state.Filter:
 			stateFilter = v
+		case *state.StateGroup:
+			stateFilter = v.Filter()
 		case MiddlewareFunc:
 			middlewares = append(middlewares, v)
 		case []MiddlewareFunc:
@@ -64,6 +110,10 @@ func (b *Bot) RegisterGroup(group *HandlerGroup) {
 	for _, handler := range group.Handlers() {
 		b.handlers.handlers = append(b.handlers.handlers, handler)
 	}
+	b.RegisterCommands.commands = append(b.RegisterCommands.commands, group.Commands()...)
+	for _, fn := range group.onRegister {
+		fn(b)
+	}
 }
 
 // OnError registers an error handler with optional filter
@@ -156,6 +206,7 @@ type PollingOptions struct {
 	AllowedUpdates []string      // List of update types to receive (default: all)
 	Async          bool          // Process updates asynchronously in goroutines (default: true)
 	RetryDelay     int           // Delay in seconds before retrying after error (default: 3)
+	SyncCommands   bool          // Sync registered commands with the command menu before polling
 	OnStart        func()        // Callback when polling starts
 	OnError        func(error)   // Callback when error occurs
 }
@@ -186,6 +237,15 @@ func (b *Bot) StartPolling(options *PollingOptions) {
 	
 	offset := int64(0)
 	
+	if options.SyncCommands {
+		if err := b.SyncCommands(context.Background()); err != nil {
+			log.Printf("Error syncing commands: %v", err)
+			if options.OnError != nil {
+				options.OnError(err)
+			}
+		}
+	}
+	
 	if options.OnStart != nil {
 		options.OnStart()
 	}
@@ -295,6 +355,10 @@ func (b *Bot) SendChatAction(chatID interface{}, action string) (bool, error) {
 	return b.requester.SendChatAction(chatID, action)
 }
 
+func (b *Bot) SendChatActionWithParams(params *models.SendChatActionParams) (bool, error) {
+	return b.requester.SendChatActionWithParams(params)
+}
+
 func (b *Bot) GetFile(fileID string) (*models.File, error) {
 	return b.requester.GetFile(fileID)
 }
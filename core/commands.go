@@ -0,0 +1,398 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/erfjab/egobot/models"
+)
+
+// CommandSpec describes how a command registered with OnCommand appears in
+// the bot's command menu.
+type CommandSpec struct {
+	Command        string
+	Description    string                   // Default description (all languages)
+	Descriptions   map[string]string        // Per-language descriptions keyed by language code
+	DescriptionKey string                   // Translation key localized through the bot's translator
+	Scopes         []models.BotCommandScope // Scopes the command is shown in (default scope if empty)
+	Hidden         bool                     // Registered as a handler but never shown in the menu
+}
+
+// CommandOption configures the CommandSpec of a command.
+// Pass it to OnCommand together with the usual handler options.
+type CommandOption func(*CommandSpec)
+
+// CommandDescription sets the default description shown in the command menu
+func CommandDescription(description string) CommandOption {
+	return func(spec *CommandSpec) {
+		spec.Description = description
+	}
+}
+
+// CommandDescriptionFor sets the description shown to users with the given language code
+func CommandDescriptionFor(languageCode, description string) CommandOption {
+	return func(spec *CommandSpec) {
+		if spec.Descriptions == nil {
+			spec.Descriptions = make(map[string]string)
+		}
+		spec.Descriptions[languageCode] = description
+	}
+}
+
+// CommandDescriptionKey localizes the description through the bot's translator.
+// Every locale with a translation for key gets its own description.
+func CommandDescriptionKey(key string) CommandOption {
+	return func(spec *CommandSpec) {
+		spec.DescriptionKey = key
+	}
+}
+
+// CommandScope limits the command to the given scopes
+func CommandScope(scopes ...models.BotCommandScope) CommandOption {
+	return func(spec *CommandSpec) {
+		spec.Scopes = append(spec.Scopes, scopes...)
+	}
+}
+
+// HiddenCommand keeps the command out of the command menu
+func HiddenCommand() CommandOption {
+	return func(spec *CommandSpec) {
+		spec.Hidden = true
+	}
+}
+
+// ScopeDefault returns the default command scope
+func ScopeDefault() models.BotCommandScope {
+	return models.BotCommandScope{Type: models.BotCommandScopeTypeDefault}
+}
+
+// ScopeAllPrivateChats returns a scope covering all private chats
+func ScopeAllPrivateChats() models.BotCommandScope {
+	return models.BotCommandScope{Type: models.BotCommandScopeTypeAllPrivateChats}
+}
+
+// ScopeAllGroupChats returns a scope covering all group and supergroup chats
+func ScopeAllGroupChats() models.BotCommandScope {
+	return models.BotCommandScope{Type: models.BotCommandScopeTypeAllGroupChats}
+}
+
+// ScopeAllChatAdministrators returns a scope covering all group administrators
+func ScopeAllChatAdministrators() models.BotCommandScope {
+	return models.BotCommandScope{Type: models.BotCommandScopeTypeAllChatAdministrators}
+}
+
+// ScopeChat returns a scope covering a specific chat
+func ScopeChat(chatID interface{}) models.BotCommandScope {
+	return models.BotCommandScope{Type: models.BotCommandScopeTypeChat, ChatID: chatID}
+}
+
+// ScopeChatAdministrators returns a scope covering the administrators of a specific chat
+func ScopeChatAdministrators(chatID interface{}) models.BotCommandScope {
+	return models.BotCommandScope{Type: models.BotCommandScopeTypeChatAdministrators, ChatID: chatID}
+}
+
+// ScopeChatMember returns a scope covering a specific member of a specific chat
+func ScopeChatMember(chatID interface{}, userID int64) models.BotCommandScope {
+	return models.BotCommandScope{Type: models.BotCommandScopeTypeChatMember, ChatID: chatID, UserID: userID}
+}
+
+// splitCommandOptions separates CommandOptions from the remaining handler options
+func splitCommandOptions(command string, opts []interface{}) (CommandSpec, []interface{}) {
+	spec := CommandSpec{Command: command}
+	rest := make([]interface{}, 0, len(opts))
+	for _, opt := range opts {
+		switch v := opt.(type) {
+		case CommandOption:
+			v(&spec)
+		case func(*CommandSpec):
+			v(&spec)
+		default:
+			rest = append(rest, opt)
+		}
+	}
+	return spec, rest
+}
+
+// commandMenu is the desired command list for one scope and language
+type commandMenu struct {
+	scope        *models.BotCommandScope
+	languageCode string
+	commands     []models.BotCommand
+}
+
+// buildCommandMenus groups command specs by scope and language.
+// Every language that has at least one localized description gets a full list,
+// falling back to the default description for commands without a translation.
+func buildCommandMenus(specs []CommandSpec) []commandMenu {
+	type scopeEntry struct {
+		scope *models.BotCommandScope
+		specs []CommandSpec
+	}
+
+	scopes := make(map[string]*scopeEntry)
+	var order []string
+	for _, spec := range specs {
+		if spec.Hidden {
+			continue
+		}
+		targets := spec.Scopes
+		if len(targets) == 0 {
+			targets = []models.BotCommandScope{ScopeDefault()}
+		}
+		for i := range targets {
+			key := commandScopeKey(&targets[i])
+			entry, ok := scopes[key]
+			if !ok {
+				scope := targets[i]
+				entry = &scopeEntry{scope: &scope}
+				scopes[key] = entry
+				order = append(order, key)
+			}
+			entry.specs = append(entry.specs, spec)
+		}
+	}
+
+	var menus []commandMenu
+	for _, key := range order {
+		entry := scopes[key]
+
+		languages := map[string]bool{"": true}
+		for _, spec := range entry.specs {
+			for lang := range spec.Descriptions {
+				languages[lang] = true
+			}
+		}
+		langList := make([]string, 0, len(languages))
+		for lang := range languages {
+			langList = append(langList, lang)
+		}
+		sort.Strings(langList)
+
+		scope := entry.scope
+		if scope.Type == models.BotCommandScopeTypeDefault {
+			scope = nil
+		}
+
+		for _, lang := range langList {
+			menu := commandMenu{scope: scope, languageCode: lang}
+			seen := make(map[string]bool)
+			for _, spec := range entry.specs {
+				if seen[spec.Command] {
+					continue
+				}
+				description := spec.Description
+				if lang != "" && spec.Descriptions[lang] != "" {
+					description = spec.Descriptions[lang]
+				}
+				if description == "" {
+					continue
+				}
+				seen[spec.Command] = true
+				menu.commands = append(menu.commands, models.BotCommand{
+					Command:     spec.Command,
+					Description: description,
+				})
+			}
+			menus = append(menus, menu)
+		}
+	}
+	return menus
+}
+
+// undescribedCommands returns the visible commands without a default
+// description, which buildCommandMenus leaves out of the menus of every
+// language they have no description for
+func undescribedCommands(specs []CommandSpec) []string {
+	var missing []string
+	for _, spec := range specs {
+		if !spec.Hidden && spec.Description == "" {
+			missing = append(missing, "/"+spec.Command)
+		}
+	}
+	return missing
+}
+
+func commandScopeKey(scope *models.BotCommandScope) string {
+	if scope == nil {
+		return string(models.BotCommandScopeTypeDefault)
+	}
+	data, err := json.Marshal(scope)
+	if err != nil {
+		return fmt.Sprintf("%v", *scope)
+	}
+	return string(data)
+}
+
+func sameCommands(a, b []models.BotCommand) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// commandSync remembers the scope/language pairs SyncCommands has managed,
+// so their menus are deleted once no registered command uses them
+type commandSync struct {
+	mu     sync.Mutex
+	menus  map[string]commandMenu // Keyed by commandMenuKey, commands unset
+	scopes []models.BotCommandScope
+}
+
+func commandMenuKey(scope *models.BotCommandScope, languageCode string) string {
+	return commandScopeKey(scope) + "|" + languageCode
+}
+
+// globalCommandScopes are checked for stale menus on every sync
+var globalCommandScopes = []models.BotCommandScope{
+	ScopeDefault(),
+	ScopeAllPrivateChats(),
+	ScopeAllGroupChats(),
+	ScopeAllChatAdministrators(),
+}
+
+// TrackCommandScopes adds chat-specific scopes that SyncCommands clears when
+// no registered command uses them anymore. Global scopes and scopes synced
+// since the bot started are tracked already; use this for chat scopes
+// dropped from the code between restarts.
+func (b *Bot) TrackCommandScopes(scopes ...models.BotCommandScope) {
+	b.commandSync.mu.Lock()
+	defer b.commandSync.mu.Unlock()
+	b.commandSync.scopes = append(b.commandSync.scopes, scopes...)
+}
+
+// commandTargets returns every scope/language pair to sync: the desired
+// menus first, then empty menus for the global and tracked scopes in every
+// known language and for pairs synced before that lost all their commands
+func (b *Bot) commandTargets(desired []commandMenu) []commandMenu {
+	b.commandSync.mu.Lock()
+	defer b.commandSync.mu.Unlock()
+
+	targets := make([]commandMenu, 0, len(desired))
+	seen := make(map[string]bool)
+	languages := map[string]bool{"": true}
+	for _, menu := range desired {
+		seen[commandMenuKey(menu.scope, menu.languageCode)] = true
+		languages[menu.languageCode] = true
+		targets = append(targets, menu)
+	}
+	if b.translator != nil {
+		for _, locale := range b.translator.Locales() {
+			// Telegram only accepts two-letter language codes
+			if len(locale) == 2 {
+				languages[locale] = true
+			}
+		}
+	}
+	for _, menu := range b.commandSync.menus {
+		languages[menu.languageCode] = true
+	}
+
+	var extra []commandMenu
+	add := func(scope *models.BotCommandScope, languageCode string) {
+		if scope != nil && scope.Type == models.BotCommandScopeTypeDefault {
+			scope = nil
+		}
+		key := commandMenuKey(scope, languageCode)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		extra = append(extra, commandMenu{scope: scope, languageCode: languageCode})
+	}
+	for _, menu := range b.commandSync.menus {
+		add(menu.scope, menu.languageCode)
+	}
+	scopes := append(append([]models.BotCommandScope(nil), globalCommandScopes...), b.commandSync.scopes...)
+	for i := range scopes {
+		for lang := range languages {
+			add(&scopes[i], lang)
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool {
+		return commandMenuKey(extra[i].scope, extra[i].languageCode) < commandMenuKey(extra[j].scope, extra[j].languageCode)
+	})
+	return append(targets, extra...)
+}
+
+// rememberCommandMenu records whether a scope/language pair holds commands
+func (b *Bot) rememberCommandMenu(menu commandMenu) {
+	b.commandSync.mu.Lock()
+	defer b.commandSync.mu.Unlock()
+	key := commandMenuKey(menu.scope, menu.languageCode)
+	if len(menu.commands) == 0 {
+		delete(b.commandSync.menus, key)
+		return
+	}
+	if b.commandSync.menus == nil {
+		b.commandSync.menus = make(map[string]commandMenu)
+	}
+	b.commandSync.menus[key] = commandMenu{scope: menu.scope, languageCode: menu.languageCode}
+}
+
+// SyncCommands pushes the commands registered via OnCommand to Telegram.
+// For every scope and language it compares the desired list with GetMyCommands
+// and only calls SetMyCommands or DeleteMyCommands when something changed.
+// Commands without a description (e.g. a DescriptionKey with no translation)
+// are left out of the menu and logged.
+// Menus left without commands are deleted: those synced earlier, and those
+// of the global scopes and TrackCommandScopes in every language the bot
+// knows (command descriptions and translator locales).
+func (b *Bot) SyncCommands(ctx context.Context) error {
+	specs := b.localizeCommands(b.Commands())
+	if missing := undescribedCommands(specs); len(missing) > 0 {
+		log.Printf("Commands without a description are left out of the command menu (in languages without one): %s", strings.Join(missing, ", "))
+	}
+	for _, menu := range b.commandTargets(buildCommandMenus(specs)) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var scope interface{}
+		if menu.scope != nil {
+			scope = menu.scope
+		}
+
+		current, err := b.GetMyCommands(models.GetMyCommandsParams{
+			Scope:        scope,
+			LanguageCode: menu.languageCode,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get commands (scope %s, language %q): %w", commandScopeKey(menu.scope), menu.languageCode, err)
+		}
+		if sameCommands(current, menu.commands) {
+			b.rememberCommandMenu(menu)
+			continue
+		}
+
+		if len(menu.commands) == 0 {
+			if _, err := b.DeleteMyCommands(models.DeleteMyCommandsParams{
+				Scope:        scope,
+				LanguageCode: menu.languageCode,
+			}); err != nil {
+				return fmt.Errorf("failed to delete commands (scope %s, language %q): %w", commandScopeKey(menu.scope), menu.languageCode, err)
+			}
+			b.rememberCommandMenu(menu)
+			continue
+		}
+
+		if _, err := b.SetMyCommands(models.SetMyCommandsParams{
+			Commands:     menu.commands,
+			Scope:        scope,
+			LanguageCode: menu.languageCode,
+		}); err != nil {
+			return fmt.Errorf("failed to set commands (scope %s, language %q): %w", commandScopeKey(menu.scope), menu.languageCode, err)
+		}
+		b.rememberCommandMenu(menu)
+	}
+	return nil
+}
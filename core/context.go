@@ -1,14 +1,20 @@
 package core
 
 import (
+	"strconv"
 	"sync"
+
+	"github.com/erfjab/egobot/models"
+	"github.com/erfjab/egobot/state"
 )
 
 // Context provides a way to store and retrieve data during request processing
 // It allows middlewares to pass data to handlers
 type Context struct {
-	data map[string]interface{}
-	mu   sync.RWMutex
+	data   map[string]interface{}
+	mu     sync.RWMutex
+	bot    *Bot
+	update *models.Update
 }
 
 // NewContext creates a new Context instance
@@ -18,6 +24,35 @@ func NewContext() *Context {
 	}
 }
 
+// NewUpdateContext creates a new Context bound to a bot and the update being processed
+func NewUpdateContext(bot *Bot, update *models.Update) *Context {
+	ctx := NewContext()
+	ctx.bind(bot, update)
+	return ctx
+}
+
+// bind attaches the bot and update unless the context is already bound
+func (c *Context) bind(bot *Bot, update *models.Update) {
+	if c.bot == nil {
+		c.bot = bot
+	}
+	if c.update == nil {
+		c.update = update
+	}
+}
+
+// Bot returns the bot processing the current update
+// Returns nil if the context is not bound to a bot
+func (c *Context) Bot() *Bot {
+	return c.bot
+}
+
+// Update returns the update being processed
+// Returns nil if the context is not bound to an update
+func (c *Context) Update() *models.Update {
+	return c.update
+}
+
 // Set stores a value in the context
 func (c *Context) Set(key string, value interface{}) {
 	c.mu.Lock()
@@ -106,6 +141,19 @@ func (c *Context) Keys() []string {
 	return keys
 }
 
+// UserState returns the state manager for whoever sent the current update
+// Returns nil if the context is not bound or the update has no sender
+func (c *Context) UserState() *state.UserStateManager {
+	if c.bot == nil || c.bot.StateManager == nil {
+		return nil
+	}
+	userManager, ok := c.bot.StateManager.ForUpdate(c.update)
+	if !ok {
+		return nil
+	}
+	return userManager
+}
+
 // GetStateData returns the user's state data as a map
 // Returns nil if no data exists
 func (c *Context) GetStateData() map[string]interface{} {
@@ -151,3 +199,68 @@ func (c *Context) MatchCallbackData(expect interface{}) bool {
 	}
 	return callbackStructPatternMatches(c.GetCallbackData(), expect)
 }
+
+// Params returns the named parameters captured by OnRegex or OnCallbackPattern.
+// Returns nil if the handler was not registered with a pattern.
+func (c *Context) Params() map[string]string {
+	if params, ok := c.Get(paramsContextKey).(map[string]string); ok {
+		return params
+	}
+	return nil
+}
+
+// Param returns a captured parameter
+// Returns empty string if the parameter doesn't exist
+func (c *Context) Param(name string) string {
+	return c.Params()[name]
+}
+
+// ParamInt returns a captured parameter parsed as int
+// Returns 0 if the parameter doesn't exist or is not an int
+func (c *Context) ParamInt(name string) int {
+	i, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		return 0
+	}
+	return i
+}
+
+// ParamInt64 returns a captured parameter parsed as int64
+// Returns 0 if the parameter doesn't exist or is not an int64
+func (c *Context) ParamInt64(name string) int64 {
+	i, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return i
+}
+
+// ParamFloat returns a captured parameter parsed as float64
+// Returns 0 if the parameter doesn't exist or is not a number
+func (c *Context) ParamFloat(name string) float64 {
+	f, err := strconv.ParseFloat(c.Param(name), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// ParamBool returns a captured parameter parsed as bool
+// Returns false if the parameter doesn't exist or is not a bool
+func (c *Context) ParamBool(name string) bool {
+	b, err := strconv.ParseBool(c.Param(name))
+	if err != nil {
+		return false
+	}
+	return b
+}
+
+// RegexMatch returns the full match followed by all capture groups
+// (as returned by regexp.FindStringSubmatch) for handlers registered with
+// OnRegex or OnCallbackPattern. Returns nil otherwise.
+func (c *Context) RegexMatch() []string {
+	if match, ok := c.Get(regexMatchContextKey).([]string); ok {
+		return match
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/erfjab/egobot/models"
+)
+
+var (
+	ErrContextNotBound = errors.New("context is not bound to a bot and update")
+	ErrNoChat          = errors.New("update has no chat")
+	ErrNoUser          = errors.New("update has no user")
+	ErrNoMessage       = errors.New("update has no message to act on")
+	ErrNoCallbackQuery = errors.New("update is not a callback query")
+	ErrMarkupNotInline = errors.New("reply markup must be *models.InlineKeyboardMarkup when editing")
+)
+
+const callbackAnsweredContextKey = "callback_answered"
+
+// MessageOptions holds optional parameters for the Context send and edit helpers
+type MessageOptions struct {
+	ParseMode             string
+	Entities              []models.MessageEntity
+	ReplyMarkup           interface{}
+	DisableWebPagePreview bool
+	DisableNotification   bool
+	ProtectContent        bool
+	Quote                 bool // Send as a reply to the update's message
+}
+
+// MessageOption configures MessageOptions
+type MessageOption func(*MessageOptions)
+
+// WithParseMode sets the parse mode (HTML, MarkdownV2, Markdown)
+func WithParseMode(parseMode string) MessageOption {
+	return func(o *MessageOptions) {
+		o.ParseMode = parseMode
+	}
+}
+
+// WithEntities sets explicit message entities instead of a parse mode
+func WithEntities(entities []models.MessageEntity) MessageOption {
+	return func(o *MessageOptions) {
+		o.Entities = entities
+	}
+}
+
+// WithReplyMarkup sets the reply markup.
+// Edits only accept *models.InlineKeyboardMarkup.
+func WithReplyMarkup(markup interface{}) MessageOption {
+	return func(o *MessageOptions) {
+		o.ReplyMarkup = markup
+	}
+}
+
+// WithoutWebPagePreview disables link previews
+func WithoutWebPagePreview() MessageOption {
+	return func(o *MessageOptions) {
+		o.DisableWebPagePreview = true
+	}
+}
+
+// Silent sends the message without notification
+func Silent() MessageOption {
+	return func(o *MessageOptions) {
+		o.DisableNotification = true
+	}
+}
+
+// Protected protects the message from forwarding and saving
+func Protected() MessageOption {
+	return func(o *MessageOptions) {
+		o.ProtectContent = true
+	}
+}
+
+// Quote sends the message as a reply to the update's message
+func Quote() MessageOption {
+	return func(o *MessageOptions) {
+		o.Quote = true
+	}
+}
+
+func buildMessageOptions(opts []MessageOption) *MessageOptions {
+	options := &MessageOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+	return options
+}
+
+// message returns the message the update refers to: the received message or
+// channel post, or the message a callback button is attached to
+func (c *Context) message() *models.Message {
+	return c.update.EffectiveMessage()
+}
+
+// inlineMessageID returns the inline message a callback button is attached to
+func (c *Context) inlineMessageID() string {
+	if c.update != nil && c.update.CallbackQuery != nil {
+		return c.update.CallbackQuery.InlineMessageID
+	}
+	return ""
+}
+
+// threadID returns the forum topic of the update's message, if any
+func (c *Context) threadID() int64 {
+	if msg := c.message(); msg != nil && msg.IsTopicMessage {
+		return msg.MessageThreadID
+	}
+	return 0
+}
+
+// Reply sends a text message to the chat of the current update.
+// Messages in forum topics are answered in the same topic.
+func (c *Context) Reply(text string, opts ...MessageOption) (*models.Message, error) {
+	if c.bot == nil {
+		return nil, ErrContextNotBound
+	}
+	msg := c.message()
+	if msg == nil {
+		return nil, ErrNoChat
+	}
+
+	options := buildMessageOptions(opts)
+	params := &models.SendMessageParams{
+		ChatID:                msg.Chat.ID,
+		MessageThreadID:       c.threadID(),
+		Text:                  text,
+		ParseMode:             options.ParseMode,
+		Entities:              options.Entities,
+		DisableWebPagePreview: options.DisableWebPagePreview,
+		DisableNotification:   options.DisableNotification,
+		ProtectContent:        options.ProtectContent,
+		ReplyMarkup:           options.ReplyMarkup,
+	}
+	if options.Quote {
+		params.ReplyToMessageID = msg.MessageID
+	}
+	return c.bot.SendMessage(params)
+}
+
+// Answer answers the callback query of the current update with an optional notification text
+func (c *Context) Answer(text string) error {
+	return c.answerCallback(text, false)
+}
+
+// AnswerAlert answers the callback query of the current update with an alert
+func (c *Context) AnswerAlert(text string) error {
+	return c.answerCallback(text, true)
+}
+
+func (c *Context) answerCallback(text string, showAlert bool) error {
+	if c.bot == nil {
+		return ErrContextNotBound
+	}
+	if c.update == nil || c.update.CallbackQuery == nil {
+		return ErrNoCallbackQuery
+	}
+	_, err := c.bot.AnswerCallbackQuery(c.update.CallbackQuery.ID, text, showAlert)
+	if err == nil {
+		c.Set(callbackAnsweredContextKey, true)
+	}
+	return err
+}
+
+// Answered reports whether the callback query was already answered through this context
+func (c *Context) Answered() bool {
+	return c.GetBool(callbackAnsweredContextKey)
+}
+
+// EditText edits the text of the message the update refers to.
+// For callback queries this is the message (or inline message) holding the button.
+// Returns a nil message when an inline message was edited.
+func (c *Context) EditText(text string, opts ...MessageOption) (*models.Message, error) {
+	if c.bot == nil {
+		return nil, ErrContextNotBound
+	}
+
+	options := buildMessageOptions(opts)
+	markup, ok := inlineMarkup(options.ReplyMarkup)
+	if !ok {
+		return nil, ErrMarkupNotInline
+	}
+
+	params := &models.EditMessageTextParams{
+		Text:                  text,
+		ParseMode:             options.ParseMode,
+		Entities:              options.Entities,
+		DisableWebPagePreview: options.DisableWebPagePreview,
+		ReplyMarkup:           markup,
+	}
+	if id := c.inlineMessageID(); id != "" {
+		params.InlineMessageID = id
+	} else if msg := c.message(); msg != nil {
+		params.ChatID = msg.Chat.ID
+		params.MessageID = msg.MessageID
+	} else {
+		return nil, ErrNoMessage
+	}
+	return c.bot.EditMessageText(params)
+}
+
+// EditMarkup replaces the inline keyboard of the message the update refers to.
+// Pass nil to remove the keyboard.
+// Returns a nil message when an inline message was edited.
+func (c *Context) EditMarkup(markup *models.InlineKeyboardMarkup) (*models.Message, error) {
+	if c.bot == nil {
+		return nil, ErrContextNotBound
+	}
+
+	params := &models.EditMessageReplyMarkupParams{
+		ReplyMarkup: markup,
+	}
+	if id := c.inlineMessageID(); id != "" {
+		params.InlineMessageID = id
+	} else if msg := c.message(); msg != nil {
+		params.ChatID = msg.Chat.ID
+		params.MessageID = int(msg.MessageID)
+	} else {
+		return nil, ErrNoMessage
+	}
+	return c.bot.EditMessageReplyMarkup(params)
+}
+
+// DeleteMessage deletes the message the update refers to.
+// Inline messages cannot be deleted.
+func (c *Context) DeleteMessage() error {
+	if c.bot == nil {
+		return ErrContextNotBound
+	}
+	msg := c.message()
+	if msg == nil {
+		return ErrNoMessage
+	}
+	_, err := c.bot.DeleteMessage(&models.DeleteMessageParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.MessageID,
+	})
+	return err
+}
+
+// SendChatAction shows a chat action (typing, upload_photo, ...) in the chat
+// and forum topic of the current update.
+func (c *Context) SendChatAction(action string) error {
+	if c.bot == nil {
+		return ErrContextNotBound
+	}
+	msg := c.message()
+	if msg == nil {
+		return ErrNoChat
+	}
+	_, err := c.bot.SendChatActionWithParams(&models.SendChatActionParams{
+		ChatID:          msg.Chat.ID,
+		MessageThreadID: c.threadID(),
+		Action:          action,
+	})
+	return err
+}
+
+func inlineMarkup(markup interface{}) (*models.InlineKeyboardMarkup, bool) {
+	switch v := markup.(type) {
+	case nil:
+		return nil, true
+	case *models.InlineKeyboardMarkup:
+		return v, true
+	case models.InlineKeyboardMarkup:
+		return &v, true
+	default:
+		return nil, false
+	}
+}
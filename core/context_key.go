@@ -0,0 +1,68 @@
+package core
+
+import "fmt"
+
+// ContextKey is a typed key for values stored in a Context.
+// Declare keys once as package-level variables so typos become compile errors:
+//
+//	var CurrentUser = core.NewContextKey[*db.User]("current_user")
+//
+//	// in a middleware
+//	CurrentUser.Set(ctx, user)
+//
+//	// in a handler
+//	user := CurrentUser.MustGet(ctx)
+type ContextKey[T any] struct {
+	name string
+}
+
+// NewContextKey creates a typed context key
+func NewContextKey[T any](name string) ContextKey[T] {
+	return ContextKey[T]{name: name}
+}
+
+// Name returns the underlying Context key
+func (k ContextKey[T]) Name() string {
+	return k.name
+}
+
+// Set stores value in the context
+func (k ContextKey[T]) Set(ctx *Context, value T) {
+	ctx.Set(k.name, value)
+}
+
+// Get returns the value stored in the context.
+// Returns false if the key is missing or holds a value of another type.
+func (k ContextKey[T]) Get(ctx *Context) (T, bool) {
+	value, ok := ctx.Get(k.name).(T)
+	return value, ok
+}
+
+// GetOr returns the value stored in the context, or fallback if it is missing
+func (k ContextKey[T]) GetOr(ctx *Context, fallback T) T {
+	if value, ok := k.Get(ctx); ok {
+		return value
+	}
+	return fallback
+}
+
+// MustGet returns the value stored in the context and panics if it is missing
+func (k ContextKey[T]) MustGet(ctx *Context) T {
+	value, ok := k.Get(ctx)
+	if !ok {
+		var zero T
+		panic(fmt.Sprintf("context key %q: no value of type %T", k.name, zero))
+	}
+	return value
+}
+
+// Has reports whether the context holds a value of type T for the key
+func (k ContextKey[T]) Has(ctx *Context) bool {
+	_, ok := k.Get(ctx)
+	return ok
+}
+
+// Delete removes the value from the context
+func (k ContextKey[T]) Delete(ctx *Context) {
+	ctx.Delete(k.name)
+}
@@ -0,0 +1,665 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erfjab/egobot/models"
+	"github.com/erfjab/egobot/state"
+)
+
+var (
+	ErrConversationNoSender = errors.New("conversation: update has no sender to keep state for")
+	ErrConversationNoSteps  = errors.New("conversation: no steps defined")
+	ErrInvalidInput         = errors.New("conversation: invalid input")
+)
+
+// InputError is a validation error with the text sent to the user.
+// It matches ErrInvalidInput with errors.Is.
+type InputError struct {
+	Reason  string // Lowercase description for logs, e.g. "not a number"
+	Message string // Sent to the user, e.g. "Please send a number."
+}
+
+// NewInputError returns an InputError
+func NewInputError(reason, message string) *InputError {
+	return &InputError{Reason: reason, Message: message}
+}
+
+func (e *InputError) Error() string {
+	return "conversation: invalid input: " + e.Reason
+}
+
+// Is reports whether target is ErrInvalidInput
+func (e *InputError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// inputErrorMessage returns the text telling the user their input was rejected
+func inputErrorMessage(err error) string {
+	var inputErr *InputError
+	if errors.As(err, &inputErr) && inputErr.Message != "" {
+		return inputErr.Message
+	}
+	return err.Error()
+}
+
+// PromptFunc asks the user for the input of a conversation step
+type PromptFunc func(ctx *Context, data ConversationData) error
+
+// ValidatorFunc validates the input of a conversation step and returns the value to store.
+// A returned error is sent to the user and the step is asked again: the
+// Message of an *InputError, or the text of any other error.
+type ValidatorFunc func(ctx *Context) (interface{}, error)
+
+// ConversationCompleteFunc is called with the collected data once all steps are done
+type ConversationCompleteFunc func(bot *Bot, update *models.Update, ctx *Context, data ConversationData) error
+
+// ConversationStep is a single step of a Conversation
+type ConversationStep struct {
+	Name     string
+	Prompt   PromptFunc
+	Validate ValidatorFunc
+	state    *state.State
+}
+
+// State returns the state the user is in while this step waits for input
+func (s *ConversationStep) State() *state.State {
+	return s.state
+}
+
+// Conversation is a declarative multi-step flow (wizard) built on top of state.Manager.
+//
+// Example:
+//
+//	signup := core.NewConversation("Signup").
+//		EntryCommand("signup").
+//		Step("name", "What's your name?", core.TextInput()).
+//		Step("age", "How old are you?", core.IntInput()).
+//		OnComplete(func(bot *core.Bot, update *models.Update, ctx *core.Context, data core.ConversationData) error {
+//			_, err := ctx.Reply(fmt.Sprintf("Welcome %s (%d)", data.String("name"), data.Int("age")))
+//			return err
+//		})
+//	bot.OnConversation(signup)
+//
+// Every step gets its own state in a StateGroup named after the conversation.
+// Collected values are kept in the user's data under "<name>:<step>" keys.
+type Conversation struct {
+	name          string
+	group         *state.StateGroup
+	steps         []*ConversationStep
+	entry         FilterFunc
+	cancelCommand string
+	cancelText    string
+	backCommand   string
+	backText      string
+	timeout       time.Duration
+	timeoutText   string
+	onComplete    ConversationCompleteFunc
+}
+
+// NewConversation creates a new conversation.
+// By default /cancel aborts and /back returns to the previous step.
+func NewConversation(name string) *Conversation {
+	return &Conversation{
+		name:          name,
+		group:         state.NewStateGroup(name),
+		cancelCommand: "cancel",
+		cancelText:    "Cancelled.",
+		backCommand:   "back",
+	}
+}
+
+// Name returns the name of this conversation
+func (c *Conversation) Name() string {
+	return c.name
+}
+
+// StateGroup returns the group holding the states of all steps
+func (c *Conversation) StateGroup() *state.StateGroup {
+	return c.group
+}
+
+// Steps returns the steps in order
+func (c *Conversation) Steps() []*ConversationStep {
+	return c.steps
+}
+
+// Entry sets the filter that starts (or restarts) the conversation.
+// It is checked before step input, so keep it specific (a command or callback data).
+func (c *Conversation) Entry(filter FilterFunc) *Conversation {
+	c.entry = filter
+	return c
+}
+
+// EntryCommand starts (or restarts) the conversation with a command
+func (c *Conversation) EntryCommand(command string) *Conversation {
+	return c.Entry(CommandFilter(command))
+}
+
+// Step adds a step that sends prompt as a text message
+func (c *Conversation) Step(name, prompt string, validate ValidatorFunc) *Conversation {
+	return c.StepFunc(name, TextPrompt(prompt), validate)
+}
+
+// StepFunc adds a step with a custom prompt
+func (c *Conversation) StepFunc(name string, prompt PromptFunc, validate ValidatorFunc) *Conversation {
+	if validate == nil {
+		validate = TextInput()
+	}
+	c.steps = append(c.steps, &ConversationStep{
+		Name:     name,
+		Prompt:   prompt,
+		Validate: validate,
+		state:    c.group.Add(name),
+	})
+	return c
+}
+
+// Cancel sets the command that aborts the conversation and the reply sent when it does.
+// Pass an empty command to disable cancelling.
+func (c *Conversation) Cancel(command, text string) *Conversation {
+	c.cancelCommand = command
+	c.cancelText = text
+	return c
+}
+
+// Back sets the command and the (optional) plain text that go back one step.
+// Pass empty values to disable going back.
+func (c *Conversation) Back(command, text string) *Conversation {
+	c.backCommand = command
+	c.backText = text
+	return c
+}
+
+// Timeout aborts the conversation when the user takes longer than d to answer a step,
+// clearing its state and values and sending text. Only the conversation's own
+// keys are cleared: the rest of the user's data is kept.
+// On storages implementing storage.IterableStorage waiting users are checked
+// in the background, so text is sent shortly after the timeout. Otherwise the
+// timeout is checked, and text sent, when the next input arrives.
+func (c *Conversation) Timeout(d time.Duration, text string) *Conversation {
+	c.timeout = d
+	c.timeoutText = text
+	return c
+}
+
+// OnComplete sets the callback receiving the collected data
+func (c *Conversation) OnComplete(fn ConversationCompleteFunc) *Conversation {
+	c.onComplete = fn
+	return c
+}
+
+// Start enters the first step for the sender of the current update.
+// Use it to start a conversation from an existing handler.
+func (c *Conversation) Start(ctx *Context) error {
+	if len(c.steps) == 0 {
+		return ErrConversationNoSteps
+	}
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return ErrConversationNoSender
+	}
+	if err := c.reset(context.Background(), userManager); err != nil {
+		return err
+	}
+	return c.enter(ctx, userManager, 0)
+}
+
+func (c *Conversation) dataKey(field string) string {
+	return c.name + ":" + field
+}
+
+func (c *Conversation) touchedKey() string {
+	return c.dataKey("_touched")
+}
+
+func (c *Conversation) stateFilter() *state.Filter {
+	states := make([]*state.State, 0, len(c.steps))
+	for _, step := range c.steps {
+		states = append(states, step.state)
+	}
+	return state.InState(states...)
+}
+
+func (c *Conversation) stepIndex(stateName string) int {
+	for i, step := range c.steps {
+		if step.state.Name == stateName {
+			return i
+		}
+	}
+	return -1
+}
+
+// data collects the values stored for this conversation
+func (c *Conversation) data(ctx context.Context, userManager *state.UserStateManager) (ConversationData, error) {
+	raw, err := userManager.GetData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data := make(ConversationData)
+	for _, step := range c.steps {
+		if value, ok := raw[c.dataKey(step.Name)]; ok && value != nil {
+			data[step.Name] = value
+		}
+	}
+	return data, nil
+}
+
+// reset clears the state and all values of this conversation
+func (c *Conversation) reset(ctx context.Context, userManager *state.UserStateManager) error {
+	err := userManager.UpdateData(ctx, func(data map[string]interface{}) error {
+		delete(data, c.touchedKey())
+		for _, step := range c.steps {
+			delete(data, c.dataKey(step.Name))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return userManager.ClearState(ctx)
+}
+
+// enter moves the user to step i and sends its prompt
+func (c *Conversation) enter(ctx *Context, userManager *state.UserStateManager, i int) error {
+	background := context.Background()
+	step := c.steps[i]
+	if err := userManager.SetState(background, step.state); err != nil {
+		return err
+	}
+	if err := userManager.SetDataValue(background, c.touchedKey(), time.Now().Unix()); err != nil {
+		return err
+	}
+	if step.Prompt == nil {
+		return nil
+	}
+	data, err := c.data(background, userManager)
+	if err != nil {
+		return err
+	}
+	return step.Prompt(ctx, data)
+}
+
+// expired reports whether the user took longer than the timeout to answer
+func (c *Conversation) expired(ctx context.Context, userManager *state.UserStateManager) (bool, error) {
+	if c.timeout <= 0 {
+		return false, nil
+	}
+	value, err := userManager.GetDataValue(ctx, c.touchedKey())
+	if err != nil {
+		return false, err
+	}
+	touched, ok := toInt64(value)
+	if !ok {
+		return false, nil
+	}
+	return time.Since(time.Unix(touched, 0)) > c.timeout, nil
+}
+
+// timeoutInterval returns how often waiting users are checked for the timeout
+func (c *Conversation) timeoutInterval() time.Duration {
+	interval := c.timeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	return interval
+}
+
+// watchTimeouts periodically aborts the conversations that timed out.
+// Storages that can't be iterated rely on the check made on input.
+func (c *Conversation) watchTimeouts(bot *Bot) {
+	go func() {
+		ticker := time.NewTicker(c.timeoutInterval())
+		defer ticker.Stop()
+		for range ticker.C {
+			err := c.expireWaiting(context.Background(), bot)
+			if errors.Is(err, state.ErrIterationUnsupported) {
+				return
+			}
+			if err != nil {
+				log.Printf("Error expiring conversation %s: %v", c.name, err)
+			}
+		}
+	}()
+}
+
+// expireWaiting aborts the conversation of every waiting user past the timeout
+func (c *Conversation) expireWaiting(ctx context.Context, bot *Bot) error {
+	return bot.StateManager.EachInGroup(ctx, c.group, func(userManager *state.UserStateManager, _ *state.State) error {
+		expired, err := c.expired(ctx, userManager)
+		if err != nil || !expired {
+			return err
+		}
+		if err := c.reset(ctx, userManager); err != nil {
+			return err
+		}
+		chatID, ok := userManager.ChatID()
+		if c.timeoutText == "" || !ok {
+			return nil
+		}
+		if _, err := bot.SendMessage(&models.SendMessageParams{ChatID: chatID, Text: c.timeoutText}); err != nil {
+			log.Printf("Error sending conversation timeout: %v", err)
+		}
+		return nil
+	})
+}
+
+func (c *Conversation) handleEntry(_ *Bot, _ *models.Update, ctx *Context) error {
+	return c.Start(ctx)
+}
+
+func (c *Conversation) handleCancel(_ *Bot, _ *models.Update, ctx *Context) error {
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return ErrConversationNoSender
+	}
+	if err := c.reset(context.Background(), userManager); err != nil {
+		return err
+	}
+	if c.cancelText == "" {
+		return nil
+	}
+	_, err := ctx.Reply(c.cancelText)
+	return err
+}
+
+func (c *Conversation) handleBack(_ *Bot, _ *models.Update, ctx *Context) error {
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return ErrConversationNoSender
+	}
+	i := c.stepIndex(ctx.GetStateName())
+	if i < 0 {
+		return nil
+	}
+	if i > 0 {
+		i--
+	}
+	return c.enter(ctx, userManager, i)
+}
+
+func (c *Conversation) handleInput(bot *Bot, update *models.Update, ctx *Context) error {
+	background := context.Background()
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return ErrConversationNoSender
+	}
+	i := c.stepIndex(ctx.GetStateName())
+	if i < 0 {
+		return nil
+	}
+
+	expired, err := c.expired(background, userManager)
+	if err != nil {
+		return err
+	}
+	if expired {
+		if err := c.reset(background, userManager); err != nil {
+			return err
+		}
+		if c.timeoutText != "" {
+			_, err = ctx.Reply(c.timeoutText)
+		}
+		return err
+	}
+
+	value, validateErr := c.steps[i].Validate(ctx)
+	if update.CallbackQuery != nil {
+		if validateErr != nil {
+			return ctx.AnswerAlert(inputErrorMessage(validateErr))
+		}
+		if err := ctx.Answer(""); err != nil {
+			return err
+		}
+	}
+	if validateErr != nil {
+		_, err := ctx.Reply(inputErrorMessage(validateErr))
+		return err
+	}
+
+	if err := userManager.SetDataValue(background, c.dataKey(c.steps[i].Name), value); err != nil {
+		return err
+	}
+	if i+1 < len(c.steps) {
+		return c.enter(ctx, userManager, i+1)
+	}
+
+	data, err := c.data(background, userManager)
+	if err != nil {
+		return err
+	}
+	if err := c.reset(background, userManager); err != nil {
+		return err
+	}
+	if c.onComplete == nil {
+		return nil
+	}
+	return c.onComplete(bot, update, ctx, data)
+}
+
+// OnConversation registers all handlers of a conversation as one unit:
+// cancel, back, entry and step input, in that order.
+// opts (middlewares) apply to every handler of the conversation.
+func (r *RegisterCommands) OnConversation(conv *Conversation, opts ...interface{}) {
+	if conv == nil || len(conv.steps) == 0 {
+		return
+	}
+	inConversation := append([]interface{}{conv.stateFilter()}, opts...)
+
+	if conv.cancelCommand != "" {
+		r.registrar.AddHandler(CommandFilter(conv.cancelCommand), conv.handleCancel, inConversation...)
+	}
+	if conv.backCommand != "" || conv.backText != "" {
+		r.registrar.AddHandler(conv.backFilter(), conv.handleBack, inConversation...)
+	}
+	if conv.entry != nil {
+		r.registrar.AddHandler(conv.entry, conv.handleEntry, opts...)
+	}
+	if conv.timeout > 0 {
+		r.onBot(conv.watchTimeouts)
+	}
+	r.registrar.AddHandler(OrFilter(MessageFilter(), CallbackQueryFilter()), conv.handleInput, inConversation...)
+}
+
+func (c *Conversation) backFilter() FilterFunc {
+	var filters []FilterFunc
+	if c.backCommand != "" {
+		filters = append(filters, CommandFilter(c.backCommand))
+	}
+	if c.backText != "" {
+		text := c.backText
+		filters = append(filters, func(update *models.Update) bool {
+			return update.Message != nil && strings.EqualFold(strings.TrimSpace(update.Message.Text), text)
+		})
+	}
+	return OrFilter(filters...)
+}
+
+// TextPrompt returns a prompt that replies with a fixed text
+func TextPrompt(text string) PromptFunc {
+	return func(ctx *Context, _ ConversationData) error {
+		_, err := ctx.Reply(text)
+		return err
+	}
+}
+
+// TextInput accepts any non-command text message
+func TextInput() ValidatorFunc {
+	return func(ctx *Context) (interface{}, error) {
+		update := ctx.Update()
+		if update == nil || update.Message == nil || update.Message.Text == "" || strings.HasPrefix(update.Message.Text, "/") {
+			return nil, NewInputError("not a text message", "Please send a text message.")
+		}
+		return update.Message.Text, nil
+	}
+}
+
+// IntInput accepts a text message containing an integer
+func IntInput() ValidatorFunc {
+	return func(ctx *Context) (interface{}, error) {
+		update := ctx.Update()
+		if update == nil || update.Message == nil {
+			return nil, NewInputError("not a number", "Please send a number.")
+		}
+		i, err := strconv.ParseInt(strings.TrimSpace(update.Message.Text), 10, 64)
+		if err != nil {
+			return nil, NewInputError("not a number", "Please send a number.")
+		}
+		return i, nil
+	}
+}
+
+// FloatInput accepts a text message containing a decimal number
+func FloatInput() ValidatorFunc {
+	return func(ctx *Context) (interface{}, error) {
+		update := ctx.Update()
+		if update == nil || update.Message == nil {
+			return nil, NewInputError("not a number", "Please send a number.")
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(update.Message.Text), 64)
+		if err != nil {
+			return nil, NewInputError("not a number", "Please send a number.")
+		}
+		return f, nil
+	}
+}
+
+// ChoiceInput accepts one of the given choices, either as text or as callback data
+func ChoiceInput(choices ...string) ValidatorFunc {
+	return func(ctx *Context) (interface{}, error) {
+		update := ctx.Update()
+		var input string
+		switch {
+		case update == nil:
+		case update.Message != nil:
+			input = strings.TrimSpace(update.Message.Text)
+		case update.CallbackQuery != nil:
+			input = update.CallbackQuery.Data
+		}
+		for _, choice := range choices {
+			if strings.EqualFold(input, choice) {
+				return choice, nil
+			}
+		}
+		return nil, NewInputError("not one of the choices", "Please choose one of: "+strings.Join(choices, ", "))
+	}
+}
+
+// CallbackInput accepts any callback query and stores its data
+func CallbackInput() ValidatorFunc {
+	return func(ctx *Context) (interface{}, error) {
+		update := ctx.Update()
+		if update == nil || update.CallbackQuery == nil {
+			return nil, NewInputError("not a callback query", "Please use the buttons.")
+		}
+		return update.CallbackQuery.Data, nil
+	}
+}
+
+// ConversationData holds the values collected by a conversation, keyed by step name.
+// Getters tolerate the type changes caused by a JSON round-trip in persistent storages.
+type ConversationData map[string]interface{}
+
+// String returns a value as string
+// Returns empty string if the value doesn't exist
+func (d ConversationData) String(name string) string {
+	switch v := d[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Int returns a value as int
+// Returns 0 if the value doesn't exist or is not a number
+func (d ConversationData) Int(name string) int {
+	return int(d.Int64(name))
+}
+
+// Int64 returns a value as int64
+// Returns 0 if the value doesn't exist or is not a number
+func (d ConversationData) Int64(name string) int64 {
+	i, _ := toInt64(d[name])
+	return i
+}
+
+// Float returns a value as float64
+// Returns 0 if the value doesn't exist or is not a number
+func (d ConversationData) Float(name string) float64 {
+	switch v := d[name].(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	default:
+		i, _ := toInt64(v)
+		return float64(i)
+	}
+}
+
+// Bool returns a value as bool
+// Returns false if the value doesn't exist or is not a bool
+func (d ConversationData) Bool(name string) bool {
+	switch v := d[name].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+// Decode fills out (a pointer to struct) from the collected values using JSON field names
+func (d ConversationData) Decode(out interface{}) error {
+	raw, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// toInt64 converts the numeric types found in state data to int64
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	case float32:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
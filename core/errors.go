@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/erfjab/egobot/models"
@@ -38,9 +39,21 @@ func (e *TelegramError) Error() string {
 	return fmt.Sprintf("Telegram API error [%d]: %s", e.ErrorCode, e.Description)
 }
 
-// IsTelegramError checks if an error is a TelegramError
+// User returns the user that caused the update the error occurred in
+// Returns nil if the update is unknown or has no user
+func (e *TelegramError) User() *models.User {
+	return e.Update.EffectiveUser()
+}
+
+// Chat returns the chat of the update the error occurred in
+// Returns nil if the update is unknown or has no chat
+func (e *TelegramError) Chat() *models.Chat {
+	return e.Update.EffectiveChat()
+}
+
+// IsTelegramError checks if an error is, or wraps, a TelegramError
 func IsTelegramError(err error) bool {
-	_, ok := err.(*TelegramError)
+	_, ok := AsTelegramError(err)
 	return ok
 }
 
@@ -112,95 +125,57 @@ func (e *TelegramError) IsServerError() bool {
 // Message-specific error checks
 // IsMessageTextEmpty checks if error is about empty message text
 func (e *TelegramError) IsMessageTextEmpty() bool {
-	return contains(e.Description, "message text is empty")
+	return errors.Is(e, ErrMessageTextEmpty)
 }
 
 // IsMessageTooLong checks if error is about message being too long
 func (e *TelegramError) IsMessageTooLong() bool {
-	return contains(e.Description, "message is too long")
+	return errors.Is(e, ErrMessageTooLong)
 }
 
 // IsChatNotFound checks if error is about chat not being found
 func (e *TelegramError) IsChatNotFound() bool {
-	return contains(e.Description, "chat not found")
+	return errors.Is(e, ErrChatNotFound)
 }
 
 // IsMessageNotFound checks if error is about message not being found
 func (e *TelegramError) IsMessageNotFound() bool {
-	return contains(e.Description, "message to delete not found") ||
-		contains(e.Description, "message to edit not found") ||
-		contains(e.Description, "message not found")
+	return errors.Is(e, ErrMessageNotFound)
+}
+
+// IsMessageNotModified checks if an edit left the message unchanged
+func (e *TelegramError) IsMessageNotModified() bool {
+	return errors.Is(e, ErrMessageNotModified)
 }
 
 // IsMessageCantBeEdited checks if error is about message that can't be edited
 func (e *TelegramError) IsMessageCantBeEdited() bool {
-	return contains(e.Description, "message can't be edited") ||
-		contains(e.Description, "message to be edited was not found")
+	return errors.Is(e, ErrMessageCantBeEdited)
 }
 
 // IsMessageCantBeDeleted checks if error is about message that can't be deleted
 func (e *TelegramError) IsMessageCantBeDeleted() bool {
-	return contains(e.Description, "message can't be deleted") ||
-		contains(e.Description, "message to delete not found")
+	return errors.Is(e, ErrMessageCantBeDeleted)
 }
 
 // IsBotWasBlocked checks if the bot was blocked by user
 func (e *TelegramError) IsBotWasBlocked() bool {
-	return contains(e.Description, "bot was blocked by the user") ||
-		contains(e.Description, "user is deactivated") ||
-		(e.ErrorCode == ErrorCodeForbidden && contains(e.Description, "blocked"))
+	return errors.Is(e, ErrBotBlocked) || errors.Is(e, ErrUserDeactivated)
 }
 
 // IsBotKicked checks if the bot was kicked from chat
 func (e *TelegramError) IsBotKicked() bool {
-	return contains(e.Description, "bot was kicked") ||
-		contains(e.Description, "bot is not a member")
+	return errors.Is(e, ErrBotKicked)
 }
 
 // IsInvalidFileID checks if the file_id is invalid
 func (e *TelegramError) IsInvalidFileID() bool {
-	return contains(e.Description, "wrong file identifier") ||
-		contains(e.Description, "file_id")
+	return errors.Is(e, ErrInvalidFileID)
 }
 
 // IsButtonDataInvalid checks if callback data is invalid
 func (e *TelegramError) IsButtonDataInvalid() bool {
-	return contains(e.Description, "BUTTON_DATA_INVALID") ||
-		contains(e.Description, "data is too long")
-}
-
-// Helper function to check if string contains substring (case-insensitive)
-func contains(s, substr string) bool {
-	if len(substr) == 0 {
-		return true
-	}
-	if len(s) < len(substr) {
-		return false
-	}
-	
-	// Simple case-insensitive search
-	for i := 0; i <= len(s)-len(substr); i++ {
-		match := true
-		for j := 0; j < len(substr); j++ {
-			c1 := s[i+j]
-			c2 := substr[j]
-			// Convert to lowercase for comparison
-			if c1 >= 'A' && c1 <= 'Z' {
-				c1 += 32
-			}
-			if c2 >= 'A' && c2 <= 'Z' {
-				c2 += 32
-			}
-			if c1 != c2 {
-				match = false
-				break
-			}
-		}
-		if match {
-			return true
-		}
-	}
-	return false
+	return errors.Is(e, ErrButtonDataInvalid)
 }
 
 // ErrorFilter filters errors based on a condition
@@ -270,13 +245,21 @@ func TelegramErrorFilter() ErrorFilter {
 // ErrorCodeFilter creates a filter for specific error codes
 func ErrorCodeFilter(code int) ErrorFilter {
 	return func(err error) bool {
-		if teleErr, ok := err.(*TelegramError); ok {
+		if teleErr, ok := AsTelegramError(err); ok {
 			return teleErr.ErrorCode == code
 		}
 		return false
 	}
 }
 
+// ErrorIsFilter creates a filter for errors matching target with errors.Is,
+// such as the sentinel errors ErrBotBlocked or ErrMessageNotModified
+func ErrorIsFilter(target error) ErrorFilter {
+	return func(err error) bool {
+		return errors.Is(err, target)
+	}
+}
+
 // RateLimitErrorFilter creates a filter for rate limit errors (429)
 func RateLimitErrorFilter() ErrorFilter {
 	return ErrorCodeFilter(ErrorCodeTooManyRequests)
@@ -304,12 +287,7 @@ func ConflictErrorFilter() ErrorFilter {
 
 // ServerErrorFilter creates a filter for server errors (5xx)
 func ServerErrorFilter() ErrorFilter {
-	return func(err error) bool {
-		if teleErr, ok := err.(*TelegramError); ok {
-			return teleErr.IsServerError()
-		}
-		return false
-	}
+	return ErrorIsFilter(ErrServerError)
 }
 
 // AllErrorsFilter creates a filter that matches all errors
@@ -323,100 +301,62 @@ func AllErrorsFilter() ErrorFilter {
 
 // MessageTextEmptyFilter creates a filter for empty message text errors
 func MessageTextEmptyFilter() ErrorFilter {
-	return func(err error) bool {
-		if teleErr, ok := err.(*TelegramError); ok {
-			return teleErr.IsMessageTextEmpty()
-		}
-		return false
-	}
+	return ErrorIsFilter(ErrMessageTextEmpty)
 }
 
 // MessageTooLongFilter creates a filter for message too long errors
 func MessageTooLongFilter() ErrorFilter {
-	return func(err error) bool {
-		if teleErr, ok := err.(*TelegramError); ok {
-			return teleErr.IsMessageTooLong()
-		}
-		return false
-	}
+	return ErrorIsFilter(ErrMessageTooLong)
 }
 
 // ChatNotFoundFilter creates a filter for chat not found errors
 func ChatNotFoundFilter() ErrorFilter {
-	return func(err error) bool {
-		if teleErr, ok := err.(*TelegramError); ok {
-			return teleErr.IsChatNotFound()
-		}
-		return false
-	}
+	return ErrorIsFilter(ErrChatNotFound)
 }
 
 // MessageNotFoundFilter creates a filter for message not found errors
 func MessageNotFoundFilter() ErrorFilter {
-	return func(err error) bool {
-		if teleErr, ok := err.(*TelegramError); ok {
-			return teleErr.IsMessageNotFound()
-		}
-		return false
-	}
+	return ErrorIsFilter(ErrMessageNotFound)
+}
+
+// MessageNotModifiedFilter creates a filter for message is not modified errors
+func MessageNotModifiedFilter() ErrorFilter {
+	return ErrorIsFilter(ErrMessageNotModified)
 }
 
 // MessageCantBeEditedFilter creates a filter for message can't be edited errors
 func MessageCantBeEditedFilter() ErrorFilter {
-	return func(err error) bool {
-		if teleErr, ok := err.(*TelegramError); ok {
-			return teleErr.IsMessageCantBeEdited()
-		}
-		return false
-	}
+	return ErrorIsFilter(ErrMessageCantBeEdited)
 }
 
 // MessageCantBeDeletedFilter creates a filter for message can't be deleted errors
 func MessageCantBeDeletedFilter() ErrorFilter {
-	return func(err error) bool {
-		if teleErr, ok := err.(*TelegramError); ok {
-			return teleErr.IsMessageCantBeDeleted()
-		}
-		return false
-	}
+	return ErrorIsFilter(ErrMessageCantBeDeleted)
 }
 
 // BotBlockedFilter creates a filter for bot was blocked by user errors
 func BotBlockedFilter() ErrorFilter {
 	return func(err error) bool {
-		if teleErr, ok := err.(*TelegramError); ok {
-			return teleErr.IsBotWasBlocked()
-		}
-		return false
+		return errors.Is(err, ErrBotBlocked) || errors.Is(err, ErrUserDeactivated)
 	}
 }
 
 // BotKickedFilter creates a filter for bot was kicked from chat errors
 func BotKickedFilter() ErrorFilter {
-	return func(err error) bool {
-		if teleErr, ok := err.(*TelegramError); ok {
-			return teleErr.IsBotKicked()
-		}
-		return false
-	}
+	return ErrorIsFilter(ErrBotKicked)
 }
 
 // InvalidFileIDFilter creates a filter for invalid file_id errors
 func InvalidFileIDFilter() ErrorFilter {
-	return func(err error) bool {
-		if teleErr, ok := err.(*TelegramError); ok {
-			return teleErr.IsInvalidFileID()
-		}
-		return false
-	}
+	return ErrorIsFilter(ErrInvalidFileID)
 }
 
 // ButtonDataInvalidFilter creates a filter for invalid button data errors
 func ButtonDataInvalidFilter() ErrorFilter {
-	return func(err error) bool {
-		if teleErr, ok := err.(*TelegramError); ok {
-			return teleErr.IsButtonDataInvalid()
-		}
-		return false
-	}
+	return ErrorIsFilter(ErrButtonDataInvalid)
+}
+
+// FloodWaitFilter creates a filter for flood wait errors (429)
+func FloodWaitFilter() ErrorFilter {
+	return ErrorIsFilter(ErrFloodWait)
 }
@@ -11,6 +11,7 @@ type HandlerGroup struct {
 	handlers    []Handler
 	filter      FilterFunc
 	middlewares []MiddlewareFunc
+	onRegister  []func(bot *Bot)
 	*RegisterCommands
 }
 
@@ -46,6 +47,8 @@ func (g *HandlerGroup) AddHandler(filter FilterFunc, handler HandlerFunc, opts .
 		switch v := opt.(type) {
 		case *state.Filter:
 			stateFilter = v
+		case *state.StateGroup:
+			stateFilter = v.Filter()
 		case MiddlewareFunc:
 			middlewares = append(middlewares, v)
 		case func(*Bot, *models.Update, *Context, NextFunc):
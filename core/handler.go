@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"errors"
 	"log"
 	"strings"
 
@@ -58,25 +59,18 @@ func (h *Handlers) AddHandlerWithState(filter FilterFunc, stateFilter *state.Fil
 
 // Process processes an update through all handlers
 func (h *Handlers) Process(bot *Bot, update *models.Update) {
-	// Get user ID for state checking
-	var userID interface{}
-	if update.Message != nil && update.Message.From != nil {
-		userID = update.Message.From.ID
-	} else if update.CallbackQuery != nil {
-		userID = update.CallbackQuery.From.ID
-	} else if update.EditedMessage != nil && update.EditedMessage.From != nil {
-		userID = update.EditedMessage.From.ID
-	} else if update.InlineQuery != nil {
-		userID = update.InlineQuery.From.ID
-	}
+	// Drop cached admin lists of chats whose membership changed
+	bot.AdminCache.observe(update)
+
+	// Resolve the state of whoever sent the update
+	userManager, hasSender := bot.StateManager.ForUpdate(update)
 
 	for _, handler := range h.handlers {
 		if handler.Filter(update) {
 			// Check state filter if present and load user context
 			var userContext *storage.UserContext
-			if handler.StateFilter != nil && userID != nil {
+			if handler.StateFilter != nil && hasSender {
 				ctx := context.Background()
-				userManager := bot.StateManager.ForUser(userID)
 				var err error
 				userContext, err = userManager.GetContext(ctx)
 				if err != nil {
@@ -97,7 +91,7 @@ func (h *Handlers) Process(bot *Bot, update *models.Update) {
 
 			var err error
 			// Create context and inject user state/data if available
-			handlerCtx := NewContext()
+			handlerCtx := NewUpdateContext(bot, update)
 			if userContext != nil {
 				handlerCtx.Set("state", userContext.State)
 				handlerCtx.Set("data", userContext.Data)
@@ -117,6 +111,10 @@ func (h *Handlers) Process(bot *Bot, update *models.Update) {
 			
 			// If there was an error, pass it to error handlers
 			if err != nil {
+				var teleErr *TelegramError
+				if errors.As(err, &teleErr) && teleErr.Update == nil {
+					teleErr.Update = update
+				}
 				log.Printf("Error handling update: %v", err)
 				if handlerErr := bot.errorHandlers.Process(bot, update, err); handlerErr != nil {
 					log.Printf("Error handler failed: %v", handlerErr)
@@ -288,19 +286,7 @@ func EditedChannelPostFilter() FilterFunc {
 // ChatTypeFilter filters messages by chat type (private, group, supergroup, channel)
 func ChatTypeFilter(chatType models.ChatType) FilterFunc {
 	return func(update *models.Update) bool {
-		var chat *models.Chat
-		if update.Message != nil {
-			chat = &update.Message.Chat
-		} else if update.EditedMessage != nil {
-			chat = &update.EditedMessage.Chat
-		} else if update.ChannelPost != nil {
-			chat = &update.ChannelPost.Chat
-		} else if update.EditedChannelPost != nil {
-			chat = &update.EditedChannelPost.Chat
-		} else if update.CallbackQuery != nil && update.CallbackQuery.Message != nil {
-			chat = &update.CallbackQuery.Message.Chat
-		}
-		
+		chat := update.EffectiveChat()
 		if chat == nil {
 			return false
 		}
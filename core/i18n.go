@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"github.com/erfjab/egobot/i18n"
+)
+
+const localeContextKey = "locale"
+
+var ErrNoTranslator = errors.New("no translator set on the bot")
+
+// SetTranslator sets the translator used by Context.T and SyncCommands.
+// If t has no storage for locale preferences, the bot's state storage is used.
+func (b *Bot) SetTranslator(t *i18n.Translator) {
+	if t != nil && t.Storage() == nil && b.StateManager != nil {
+		t.SetStorage(b.StateManager.GetStorage())
+	}
+	b.translator = t
+}
+
+// Translator returns the translator set with SetTranslator, or nil
+func (b *Bot) Translator() *i18n.Translator {
+	return b.translator
+}
+
+// Locale returns the locale of whoever sent the current update: their stored
+// preference, their Telegram language or the translator's fallback locale.
+// Returns "" if the bot has no translator.
+func (c *Context) Locale() string {
+	if locale := c.GetString(localeContextKey); locale != "" {
+		return locale
+	}
+	if c.bot == nil || c.bot.translator == nil {
+		return ""
+	}
+	locale := c.bot.translator.UserLocale(context.Background(), c.update.EffectiveUser())
+	c.Set(localeContextKey, locale)
+	return locale
+}
+
+// SetLocale stores the locale preference of the current user and uses it for the rest of the update.
+// Pass "" to go back to the user's Telegram language.
+func (c *Context) SetLocale(locale string) error {
+	if c.bot == nil || c.bot.translator == nil {
+		return ErrNoTranslator
+	}
+	user := c.update.EffectiveUser()
+	if user == nil {
+		return ErrNoUser
+	}
+	if err := c.bot.translator.SetUserLocale(context.Background(), user.ID, locale); err != nil {
+		return err
+	}
+	c.Delete(localeContextKey)
+	return nil
+}
+
+// T translates key into the locale of the current user.
+// args are i18n.Args and/or name, value pairs; "count" selects the plural form.
+// Returns key if the bot has no translator or the key is missing.
+func (c *Context) T(key string, args ...interface{}) string {
+	if c.bot == nil || c.bot.translator == nil {
+		return key
+	}
+	return c.bot.translator.Translate(c.Locale(), key, args...)
+}
+
+// localizeCommands fills in the descriptions of commands registered with
+// CommandDescriptionKey from the translator's catalogs
+func (b *Bot) localizeCommands(specs []CommandSpec) []CommandSpec {
+	if b.translator == nil {
+		return specs
+	}
+	localized := make([]CommandSpec, len(specs))
+	for i, spec := range specs {
+		if spec.DescriptionKey != "" {
+			descriptions := make(map[string]string, len(spec.Descriptions))
+			for lang, description := range spec.Descriptions {
+				descriptions[lang] = description
+			}
+			for _, locale := range b.translator.Locales() {
+				// Telegram only accepts two-letter language codes
+				if len(locale) != 2 {
+					continue
+				}
+				if _, ok := descriptions[locale]; !ok && b.translator.Has(locale, spec.DescriptionKey) {
+					descriptions[locale] = b.translator.Translate(locale, spec.DescriptionKey)
+				}
+			}
+			if spec.Description == "" && b.translator.Has(b.translator.Fallback(), spec.DescriptionKey) {
+				spec.Description = b.translator.Translate(b.translator.Fallback(), spec.DescriptionKey)
+			}
+			spec.Descriptions = descriptions
+		}
+		localized[i] = spec
+	}
+	return localized
+}
@@ -380,12 +380,7 @@ func (r *Requester) EditMessageText(params *models.EditMessageTextParams) (*mode
 		return nil, err
 	}
 
-	var message models.Message
-	if err := r.ParseResponse(respBody, &message); err != nil {
-		return nil, err
-	}
-
-	return &message, nil
+	return r.parseEditResponse(respBody, params.InlineMessageID != "")
 }
 
 // https://core.telegram.org/bots/api#editmessagecaption
@@ -399,12 +394,7 @@ func (r *Requester) EditMessageCaption(params *models.EditMessageCaptionParams)
 		return nil, err
 	}
 
-	var message models.Message
-	if err := r.ParseResponse(respBody, &message); err != nil {
-		return nil, err
-	}
-
-	return &message, nil
+	return r.parseEditResponse(respBody, params.InlineMessageID != "")
 }
 
 // https://core.telegram.org/bots/api#editmessagemedia
@@ -422,12 +412,7 @@ func (r *Requester) EditMessageMedia(params *models.EditMessageMediaParams) (*mo
 		return nil, err
 	}
 
-	var message models.Message
-	if err := r.ParseResponse(respBody, &message); err != nil {
-		return nil, err
-	}
-
-	return &message, nil
+	return r.parseEditResponse(respBody, params.InlineMessageID != "")
 }
 
 // https://core.telegram.org/bots/api#editmessagereplymarkup
@@ -441,12 +426,7 @@ func (r *Requester) EditMessageReplyMarkup(params *models.EditMessageReplyMarkup
 		return nil, err
 	}
 
-	var message models.Message
-	if err := r.ParseResponse(respBody, &message); err != nil {
-		return nil, err
-	}
-
-	return &message, nil
+	return r.parseEditResponse(respBody, params.InlineMessageID != "")
 }
 
 // https://core.telegram.org/bots/api#editmessagelivelocation
@@ -642,3 +622,23 @@ func (r *Requester) DeleteMessages(params models.DeleteMessagesParams) (bool, er
 
 	return result, nil
 }
+
+// parseEditResponse parses the result of an edit method. Edits of inline
+// messages return true instead of the edited message, in which case the
+// returned message is nil.
+func (r *Requester) parseEditResponse(respBody []byte, inline bool) (*models.Message, error) {
+	if inline {
+		var result bool
+		if err := r.ParseResponse(respBody, &result); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	var message models.Message
+	if err := r.ParseResponse(respBody, &message); err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
@@ -26,6 +26,32 @@ var inputFileType = reflect.TypeOf(models.InputFile{})
 type Requester struct {
 	Token      string
 	HTTPClient *http.Client
+	// ErrorFactory builds the error returned for unsuccessful API responses.
+	// When nil, a plain formatted error is returned.
+	ErrorFactory func(code int, description string, params *ResponseParameters) error
+}
+
+// ResponseParameters contains information about why a request was unsuccessful
+type ResponseParameters struct {
+	MigrateToChatID int64 `json:"migrate_to_chat_id,omitempty"`
+	RetryAfter      int   `json:"retry_after,omitempty"`
+}
+
+// apiResponse is the envelope of every Bot API response
+type apiResponse struct {
+	Ok          bool                `json:"ok"`
+	Result      json.RawMessage     `json:"result,omitempty"`
+	ErrorCode   int                 `json:"error_code,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Parameters  *ResponseParameters `json:"parameters,omitempty"`
+}
+
+// apiError builds the error of an unsuccessful response
+func (r *Requester) apiError(resp *apiResponse) error {
+	if r.ErrorFactory != nil {
+		return r.ErrorFactory(resp.ErrorCode, resp.Description, resp.Parameters)
+	}
+	return fmt.Errorf("telegram API error [%d]: %s", resp.ErrorCode, resp.Description)
 }
 
 func NewRequester(token string) *Requester {
@@ -109,6 +135,11 @@ func (r *Requester) do(req *http.Request) ([]byte, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		// Error responses carry the usual JSON envelope with the error code and description
+		var apiResp apiResponse
+		if err := json.Unmarshal(respBody, &apiResp); err == nil && !apiResp.Ok && apiResp.ErrorCode != 0 {
+			return nil, r.apiError(&apiResp)
+		}
 		return nil, fmt.Errorf("telegram API error: %s", string(respBody))
 	}
 
@@ -116,19 +147,14 @@ func (r *Requester) do(req *http.Request) ([]byte, error) {
 }
 
 func (r *Requester) ParseResponse(respBody []byte, target interface{}) error {
-	var apiResp struct {
-		Ok          bool            `json:"ok"`
-		Result      json.RawMessage `json:"result,omitempty"`
-		ErrorCode   int             `json:"error_code,omitempty"`
-		Description string          `json:"description,omitempty"`
-	}
+	var apiResp apiResponse
 
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
 		return fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
 	if !apiResp.Ok {
-		return fmt.Errorf("telegram API error [%d]: %s", apiResp.ErrorCode, apiResp.Description)
+		return r.apiError(&apiResp)
 	}
 
 	if target != nil && len(apiResp.Result) > 0 {
@@ -106,13 +106,20 @@ func (r *Requester) GetUserProfilePhotos(params models.GetUserProfilePhotosParam
 
 // https://core.telegram.org/bots/api#sendchataction
 func (r *Requester) SendChatAction(chatID interface{}, action string) (bool, error) {
-	if action == "" {
-		return false, fmt.Errorf("action cannot be empty")
+	return r.SendChatActionWithParams(&models.SendChatActionParams{
+		ChatID: chatID,
+		Action: action,
+	})
+}
+
+// https://core.telegram.org/bots/api#sendchataction
+func (r *Requester) SendChatActionWithParams(params *models.SendChatActionParams) (bool, error) {
+	if params == nil {
+		return false, fmt.Errorf("params cannot be nil")
 	}
 
-	params := map[string]interface{}{
-		"chat_id": chatID,
-		"action":  action,
+	if params.Action == "" {
+		return false, fmt.Errorf("action cannot be empty")
 	}
 
 	respBody, err := r.Request("sendChatAction", params)
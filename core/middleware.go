@@ -40,6 +40,8 @@ func NewMiddlewareChainWithContext(handler HandlerFunc, ctx *Context, middleware
 
 // Execute executes the middleware chain
 func (mc *MiddlewareChain) Execute(bot *Bot, update *models.Update) error {
+	mc.context.bind(bot, update)
+
 	if len(mc.middlewares) == 0 {
 		// No middlewares, just execute the handler
 		return mc.handler(bot, update, mc.context)
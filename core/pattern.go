@@ -0,0 +1,165 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/erfjab/egobot/models"
+)
+
+const (
+	paramsContextKey     = "params"
+	regexMatchContextKey = "regex_match"
+)
+
+// matchText returns the text a regex filter is applied to:
+// message text, message caption or callback data.
+func matchText(update *models.Update) (string, bool) {
+	if update == nil {
+		return "", false
+	}
+	if update.Message != nil {
+		if update.Message.Text != "" {
+			return update.Message.Text, true
+		}
+		if update.Message.Caption != "" {
+			return update.Message.Caption, true
+		}
+		return "", false
+	}
+	if update.CallbackQuery != nil && update.CallbackQuery.Data != "" {
+		return update.CallbackQuery.Data, true
+	}
+	return "", false
+}
+
+// RegexFilter filters messages (text or caption) and callback queries (data)
+// matching the regular expression. It panics if expr does not compile.
+func RegexFilter(expr string) FilterFunc {
+	return regexFilter(regexp.MustCompile(expr))
+}
+
+func regexFilter(re *regexp.Regexp) FilterFunc {
+	return func(update *models.Update) bool {
+		text, ok := matchText(update)
+		if !ok {
+			return false
+		}
+		return re.MatchString(text)
+	}
+}
+
+// regexCaptureMiddleware stores the match and named capture groups in the context
+func regexCaptureMiddleware(re *regexp.Regexp) MiddlewareFunc {
+	return func(_ *Bot, update *models.Update, ctx *Context, next NextFunc) {
+		text, ok := matchText(update)
+		if !ok {
+			return
+		}
+		match := re.FindStringSubmatch(text)
+		if match == nil {
+			return
+		}
+
+		params := make(map[string]string)
+		for i, name := range re.SubexpNames() {
+			if i == 0 || name == "" {
+				continue
+			}
+			params[name] = match[i]
+		}
+		ctx.Set(regexMatchContextKey, match)
+		ctx.Set(paramsContextKey, params)
+		next()
+	}
+}
+
+// CompileCallbackPattern compiles a route-like callback data pattern into a regular expression.
+//
+// Segments are separated by "/". A segment of the form {name} matches any
+// non-empty text without "/", {name:int} matches a signed integer and
+// {name:<regexp>} matches the given expression, which may contain balanced
+// braces such as {id:\d{2}}. Everything else is literal.
+//
+// Example: "order/{id:int}/{action}" matches "order/42/cancel" with id=42, action=cancel.
+func CompileCallbackPattern(pattern string) (*regexp.Regexp, error) {
+	var builder strings.Builder
+	builder.WriteString("^")
+
+	for i := 0; i < len(pattern); {
+		open := strings.IndexByte(pattern[i:], '{')
+		if open < 0 {
+			builder.WriteString(regexp.QuoteMeta(pattern[i:]))
+			break
+		}
+		builder.WriteString(regexp.QuoteMeta(pattern[i : i+open]))
+		i += open
+
+		end := closingBrace(pattern[i:])
+		if end < 0 {
+			return nil, fmt.Errorf("callback pattern %q: unclosed '{'", pattern)
+		}
+		param := pattern[i+1 : i+end]
+		i += end + 1
+
+		name, kind, _ := strings.Cut(param, ":")
+		if name == "" {
+			return nil, fmt.Errorf("callback pattern %q: empty parameter name", pattern)
+		}
+
+		var expr string
+		switch kind {
+		case "", "str":
+			expr = `[^/]+`
+		case "int":
+			expr = `-?\d+`
+		default:
+			expr = kind
+		}
+		builder.WriteString("(?P<" + name + ">" + expr + ")")
+	}
+
+	builder.WriteString("$")
+	return regexp.Compile(builder.String())
+}
+
+// closingBrace returns the index of the '}' closing the '{' s starts with,
+// skipping nested braces (e.g. the quantifier in {id:\d{2}}) and escaped
+// characters. Returns -1 if it is unclosed.
+func closingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// CallbackPatternFilter filters callback queries whose data matches a route-like pattern.
+// See CompileCallbackPattern for the syntax. It panics if the pattern is invalid.
+func CallbackPatternFilter(pattern string) FilterFunc {
+	re, err := CompileCallbackPattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return callbackRegexFilter(re)
+}
+
+func callbackRegexFilter(re *regexp.Regexp) FilterFunc {
+	return func(update *models.Update) bool {
+		if update.CallbackQuery == nil {
+			return false
+		}
+		return re.MatchString(update.CallbackQuery.Data)
+	}
+}
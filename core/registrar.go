@@ -1,6 +1,8 @@
 package core
 
 import (
+	"regexp"
+
 	"github.com/erfjab/egobot/models"
 )
 
@@ -22,6 +24,18 @@ type ErrorHandlerRegistrar interface {
 // RegisterCommands provides convenience methods for registering handlers
 type RegisterCommands struct {
 	registrar HandlerRegistrar
+	commands  []CommandSpec
+}
+
+// onBot runs fn with the bot the handlers end up on: right away for a Bot,
+// or when a HandlerGroup is registered with Bot.RegisterGroup
+func (r *RegisterCommands) onBot(fn func(bot *Bot)) {
+	switch registrar := r.registrar.(type) {
+	case *Bot:
+		fn(registrar)
+	case *HandlerGroup:
+		registrar.onRegister = append(registrar.onRegister, fn)
+	}
 }
 
 // NewRegisterCommands creates a new RegisterCommands instance
@@ -30,8 +44,17 @@ func NewRegisterCommands(registrar HandlerRegistrar) *RegisterCommands {
 }
 
 // OnCommand registers a handler for a specific command
+// CommandOptions (CommandDescription, CommandScope, ...) in opts describe how the
+// command appears in the command menu; see Bot.SyncCommands.
 func (r *RegisterCommands) OnCommand(command string, handler HandlerFunc, opts ...interface{}) {
-	r.registrar.AddHandler(CommandFilter(command), handler, opts...)
+	spec, rest := splitCommandOptions(command, opts)
+	r.commands = append(r.commands, spec)
+	r.registrar.AddHandler(CommandFilter(command), handler, rest...)
+}
+
+// Commands returns the specs of all commands registered via OnCommand
+func (r *RegisterCommands) Commands() []CommandSpec {
+	return r.commands
 }
 
 // OnMessage registers a handler for all messages
@@ -124,11 +147,36 @@ func (r *RegisterCommands) OnCallbackStruct(model interface{}, handler HandlerFu
 		next()
 	})
 
-	finalOpts := make([]interface{}, 0, len(opts)+1)
-	finalOpts = append(finalOpts, injectCallbackMiddleware)
-	finalOpts = append(finalOpts, opts...)
+	r.registrar.AddHandler(filter, handler, prependOption(injectCallbackMiddleware, opts)...)
+}
 
-	r.registrar.AddHandler(filter, handler, finalOpts...)
+// OnRegex registers a handler for messages (text or caption) and callback queries
+// matching the regular expression. Named capture groups are available in the
+// handler through ctx.Param and friends, the full match through ctx.RegexMatch.
+// It panics if expr does not compile.
+func (r *RegisterCommands) OnRegex(expr string, handler HandlerFunc, opts ...interface{}) {
+	re := regexp.MustCompile(expr)
+	r.registrar.AddHandler(regexFilter(re), handler, prependOption(regexCaptureMiddleware(re), opts)...)
+}
+
+// OnCallbackPattern registers a handler for callback queries matching a route-like
+// pattern such as "order/{id:int}/{action}". Parameters are available in the
+// handler through ctx.Param, ctx.ParamInt and friends.
+// It panics if the pattern is invalid.
+func (r *RegisterCommands) OnCallbackPattern(pattern string, handler HandlerFunc, opts ...interface{}) {
+	re, err := CompileCallbackPattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	r.registrar.AddHandler(callbackRegexFilter(re), handler, prependOption(regexCaptureMiddleware(re), opts)...)
+}
+
+// prependOption returns opts with opt placed first, so injecting middlewares run
+// before any user supplied middleware.
+func prependOption(opt interface{}, opts []interface{}) []interface{} {
+	finalOpts := make([]interface{}, 0, len(opts)+1)
+	finalOpts = append(finalOpts, opt)
+	return append(finalOpts, opts...)
 }
 
 // OnPhoto registers a handler for photo messages
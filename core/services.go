@@ -0,0 +1,118 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Services is a container of shared services (database, config, API clients, ...)
+// keyed by their type. Register services once with Provide or ProvideLazy and
+// resolve them in handlers with Resolve, or through the Context with Service.
+type Services struct {
+	mu        sync.RWMutex
+	instances map[reflect.Type]interface{}
+	factories map[reflect.Type]*lazyService
+}
+
+type lazyService struct {
+	once    sync.Once
+	factory func() (interface{}, error)
+	value   interface{}
+	err     error
+}
+
+// NewServices creates an empty service container
+func NewServices() *Services {
+	return &Services{
+		instances: make(map[reflect.Type]interface{}),
+		factories: make(map[reflect.Type]*lazyService),
+	}
+}
+
+func serviceType[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Provide registers service as the instance of type T, replacing any previous one.
+// Use an interface type parameter to register an implementation behind an interface:
+//
+//	core.Provide[Repository](bot, postgresRepo)
+func Provide[T any](bot *Bot, service T) {
+	s := bot.Services
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := serviceType[T]()
+	delete(s.factories, t)
+	s.instances[t] = service
+}
+
+// ProvideLazy registers a factory creating the instance of type T on first use.
+// The factory runs at most once; its error is returned by every Resolve.
+func ProvideLazy[T any](bot *Bot, factory func() (T, error)) {
+	s := bot.Services
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := serviceType[T]()
+	delete(s.instances, t)
+	s.factories[t] = &lazyService{factory: func() (interface{}, error) {
+		return factory()
+	}}
+}
+
+// Resolve returns the service of type T.
+// Returns an error if no service of type T was provided or its factory failed.
+func Resolve[T any](bot *Bot) (T, error) {
+	var zero T
+	s := bot.Services
+	t := serviceType[T]()
+
+	s.mu.RLock()
+	instance, ok := s.instances[t]
+	lazy := s.factories[t]
+	s.mu.RUnlock()
+
+	if !ok {
+		if lazy == nil {
+			return zero, fmt.Errorf("service %v not provided", t)
+		}
+		lazy.once.Do(func() {
+			lazy.value, lazy.err = lazy.factory()
+		})
+		if lazy.err != nil {
+			return zero, fmt.Errorf("service %v: %w", t, lazy.err)
+		}
+		instance = lazy.value
+	}
+	service, _ := instance.(T) // nil interface values resolve to the zero T
+	return service, nil
+}
+
+// MustResolve returns the service of type T and panics if it cannot be resolved.
+// Use it at startup to fail fast on missing services.
+func MustResolve[T any](bot *Bot) T {
+	service, err := Resolve[T](bot)
+	if err != nil {
+		panic(err)
+	}
+	return service
+}
+
+// Service returns the service of type T from the bot processing the current update
+func Service[T any](ctx *Context) (T, error) {
+	if ctx.bot == nil {
+		var zero T
+		return zero, ErrContextNotBound
+	}
+	return Resolve[T](ctx.bot)
+}
+
+// MustService returns the service of type T from the bot processing the
+// current update and panics if it cannot be resolved
+func MustService[T any](ctx *Context) T {
+	service, err := Service[T](ctx)
+	if err != nil {
+		panic(err)
+	}
+	return service
+}
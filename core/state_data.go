@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"github.com/erfjab/egobot/state"
+)
+
+// ErrNoUserState is returned when the update has no state (e.g. no sender)
+var ErrNoUserState = errors.New("update has no user state")
+
+// GetStateDataAs decodes the user's state data into a T with the state
+// manager's DataCodec. Handlers registered with a state filter decode the
+// data loaded when the filter matched; others load it from storage.
+//
+//	bot.OnMessage(func(bot *core.Bot, update *models.Update, ctx *core.Context) error {
+//		order, err := core.GetStateDataAs[Order](ctx)
+//		...
+//	}, OrderStates.Quantity)
+func GetStateDataAs[T any](ctx *Context) (T, error) {
+	var zero T
+	if ctx == nil || ctx.bot == nil || ctx.bot.StateManager == nil {
+		return zero, ErrContextNotBound
+	}
+	codec := ctx.bot.StateManager.DataCodec()
+	if data, ok := ctx.Get("data").(map[string]interface{}); ok {
+		return state.DecodeData[T](codec, data)
+	}
+
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return zero, ErrNoUserState
+	}
+	return state.GetDataAs[T](context.Background(), userManager)
+}
+
+// SetStateDataFrom encodes value and merges its fields into the user's
+// state data, keeping the data seen by GetStateData in sync
+func SetStateDataFrom[T any](ctx *Context, value T) error {
+	if ctx == nil || ctx.bot == nil || ctx.bot.StateManager == nil {
+		return ErrContextNotBound
+	}
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return ErrNoUserState
+	}
+	if err := state.SetDataFrom(context.Background(), userManager, value); err != nil {
+		return err
+	}
+
+	if data, ok := ctx.Get("data").(map[string]interface{}); ok {
+		fields, err := state.EncodeData(userManager.DataCodec(), value)
+		if err != nil {
+			return err
+		}
+		merged := make(map[string]interface{}, len(data)+len(fields))
+		for k, v := range data {
+			merged[k] = v
+		}
+		for k, v := range fields {
+			merged[k] = v
+		}
+		ctx.Set("data", merged)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// StateDOT returns the state transition graph together with the handlers
+// bound to each state, in Graphviz DOT format
+func (b *Bot) StateDOT() string {
+	var builder strings.Builder
+	_ = b.WriteStateDOT(&builder)
+	return builder.String()
+}
+
+// WriteStateDOT writes the state transition graph (if one is set on the
+// StateManager) and the handlers bound to each state as a Graphviz digraph.
+// States are boxes with solid transition edges; handlers are ellipses linked
+// to the states they handle by dashed edges.
+func (b *Bot) WriteStateDOT(w io.Writer) error {
+	var builder strings.Builder
+	builder.WriteString("digraph \"bot\" {\n")
+
+	if graph := b.StateManager.Graph(); graph != nil {
+		if err := graph.WriteDOTBody(&builder); err != nil {
+			return err
+		}
+	} else {
+		builder.WriteString("\trankdir=LR;\n\tnode [shape=box, style=rounded];\n")
+	}
+
+	for i, handler := range b.handlers.handlers {
+		if handler.StateFilter == nil || handler.StateFilter.IsIgnoreState() {
+			continue
+		}
+		node := fmt.Sprintf("handler_%d", i)
+		fmt.Fprintf(&builder, "\t%s [shape=ellipse, label=%s];\n", node, strconv.Quote(handlerName(handler.Handler)))
+
+		if handler.StateFilter.AllowNoState() {
+			fmt.Fprintf(&builder, "\t%s -> %s [style=dashed];\n", strconv.Quote("<none>"), node)
+		}
+		for _, st := range handler.StateFilter.GetStates() {
+			if st == nil {
+				continue
+			}
+			fmt.Fprintf(&builder, "\t%s -> %s [style=dashed];\n", strconv.Quote(st.Name), node)
+		}
+	}
+
+	builder.WriteString("}\n")
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// handlerName returns a short name for a handler function
+func handlerName(handler HandlerFunc) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer())
+	if fn == nil {
+		return "handler"
+	}
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
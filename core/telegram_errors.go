@@ -0,0 +1,175 @@
+package core
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/erfjab/egobot/core/methods"
+)
+
+// Sentinel errors for well-known Telegram API failures.
+// A *TelegramError matches them with errors.Is, also when wrapped:
+//
+//	if errors.Is(err, core.ErrBotBlocked) {
+//		markInactive(userID)
+//	}
+var (
+	// Status code based
+	ErrUnauthorized = errors.New("unauthorized: invalid bot token")
+	ErrFloodWait    = errors.New("too many requests: flood wait")
+	ErrConflict     = errors.New("conflict: another getUpdates or webhook is active")
+	ErrServerError  = errors.New("telegram server error")
+
+	// Chats and users
+	ErrBotBlocked               = errors.New("bot was blocked by the user")
+	ErrUserDeactivated          = errors.New("user is deactivated")
+	ErrBotKicked                = errors.New("bot was kicked from the chat")
+	ErrChatNotFound             = errors.New("chat not found")
+	ErrUserNotFound             = errors.New("user not found")
+	ErrGroupMigrated            = errors.New("group chat was upgraded to a supergroup")
+	ErrCantInitiateConversation = errors.New("bot can't initiate conversation with a user")
+	ErrBotCantSendToBots        = errors.New("bot can't send messages to bots")
+	ErrNotEnoughRights          = errors.New("not enough rights")
+	ErrChatAdminRequired        = errors.New("chat admin rights required")
+	ErrChatWriteForbidden       = errors.New("bot has no rights to send messages in the chat")
+	ErrPeerIDInvalid            = errors.New("invalid peer ID")
+	ErrTopicClosed              = errors.New("forum topic is closed")
+	ErrThreadNotFound           = errors.New("message thread not found")
+
+	// Messages
+	ErrMessageNotModified   = errors.New("message is not modified")
+	ErrMessageNotFound      = errors.New("message not found")
+	ErrReplyMessageNotFound = errors.New("replied message not found")
+	ErrMessageCantBeEdited  = errors.New("message can't be edited")
+	ErrMessageCantBeDeleted = errors.New("message can't be deleted")
+	ErrMessageTextEmpty     = errors.New("message text is empty")
+	ErrMessageTooLong       = errors.New("message is too long")
+	ErrCaptionTooLong       = errors.New("message caption is too long")
+	ErrCantParseEntities    = errors.New("can't parse entities")
+
+	// Callbacks, keyboards and files
+	ErrQueryTooOld       = errors.New("query is too old or the query ID is invalid")
+	ErrButtonDataInvalid = errors.New("invalid callback button data")
+	ErrButtonURLInvalid  = errors.New("invalid button URL")
+	ErrInvalidFileID     = errors.New("invalid file identifier")
+	ErrFileTooBig        = errors.New("file is too big")
+)
+
+// telegramErrorMatcher maps API error descriptions to a sentinel error
+type telegramErrorMatcher struct {
+	err       error
+	code      int      // Required error code, 0 for any
+	fragments []string // Lowercase description fragments, any of which matches
+}
+
+// telegramErrorTable lists known descriptions of the Bot API
+var telegramErrorTable = []telegramErrorMatcher{
+	{err: ErrBotBlocked, fragments: []string{"bot was blocked by the user"}},
+	{err: ErrBotBlocked, code: ErrorCodeForbidden, fragments: []string{"blocked"}},
+	{err: ErrUserDeactivated, fragments: []string{"user is deactivated"}},
+	{err: ErrBotKicked, fragments: []string{
+		"bot was kicked",
+		"bot is not a member",
+		"bot was removed from",
+	}},
+	{err: ErrChatNotFound, fragments: []string{"chat not found"}},
+	{err: ErrUserNotFound, fragments: []string{"user not found", "participant_id_invalid", "user_id_invalid"}},
+	{err: ErrGroupMigrated, fragments: []string{"group chat was upgraded to a supergroup"}},
+	{err: ErrCantInitiateConversation, fragments: []string{"bot can't initiate conversation", "bot can't send messages to the user"}},
+	{err: ErrBotCantSendToBots, fragments: []string{"bot can't send messages to bots"}},
+	{err: ErrNotEnoughRights, fragments: []string{"not enough rights", "have no rights", "chat_admin_required"}},
+	{err: ErrChatAdminRequired, fragments: []string{"chat_admin_required", "need administrator rights"}},
+	{err: ErrChatWriteForbidden, fragments: []string{"have no rights to send a message", "chat_write_forbidden", "chat_send_plain_forbidden"}},
+	{err: ErrPeerIDInvalid, fragments: []string{"peer_id_invalid"}},
+	{err: ErrTopicClosed, fragments: []string{"topic_closed"}},
+	{err: ErrThreadNotFound, fragments: []string{"message thread not found", "topic_deleted"}},
+
+	{err: ErrMessageNotModified, fragments: []string{"message is not modified"}},
+	{err: ErrMessageNotFound, fragments: []string{
+		"message to delete not found",
+		"message to edit not found",
+		"message not found",
+		"message to forward not found",
+		"message to copy not found",
+		"message_id_invalid",
+	}},
+	{err: ErrReplyMessageNotFound, fragments: []string{"replied message not found", "reply message not found"}},
+	{err: ErrMessageCantBeEdited, fragments: []string{
+		"message can't be edited",
+		"message to be edited was not found",
+	}},
+	{err: ErrMessageCantBeDeleted, fragments: []string{
+		"message can't be deleted",
+		"message to delete not found",
+	}},
+	{err: ErrMessageTextEmpty, fragments: []string{"message text is empty", "text must be non-empty"}},
+	{err: ErrMessageTooLong, fragments: []string{"message is too long", "message_too_long"}},
+	{err: ErrCaptionTooLong, fragments: []string{"message caption is too long", "media_caption_too_long"}},
+	{err: ErrCantParseEntities, fragments: []string{"can't parse entities", "can't find end of the entity"}},
+
+	{err: ErrQueryTooOld, fragments: []string{"query is too old", "query id is invalid"}},
+	{err: ErrButtonDataInvalid, fragments: []string{"button_data_invalid", "data is too long"}},
+	{err: ErrButtonURLInvalid, fragments: []string{"button_url_invalid", "wrong http url"}},
+	{err: ErrInvalidFileID, fragments: []string{
+		"wrong file identifier",
+		"wrong remote file identifier",
+		"file_id",
+	}},
+	{err: ErrFileTooBig, fragments: []string{"file is too big", "request entity too large"}},
+}
+
+// Is matches the sentinel errors of this package, so errors.Is works on
+// Telegram errors and on errors wrapping them
+func (e *TelegramError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.ErrorCode == ErrorCodeUnauthorized
+	case ErrFloodWait:
+		return e.ErrorCode == ErrorCodeTooManyRequests
+	case ErrConflict:
+		return e.ErrorCode == ErrorCodeConflict
+	case ErrServerError:
+		return e.IsServerError()
+	case ErrGroupMigrated:
+		if e.Parameters != nil && e.Parameters.MigrateToChatID != 0 {
+			return true
+		}
+	}
+
+	description := strings.ToLower(e.Description)
+	for _, matcher := range telegramErrorTable {
+		if matcher.err != target {
+			continue
+		}
+		if matcher.code != 0 && matcher.code != e.ErrorCode {
+			continue
+		}
+		for _, fragment := range matcher.fragments {
+			if strings.Contains(description, fragment) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AsTelegramError returns the TelegramError in err's chain
+func AsTelegramError(err error) (*TelegramError, bool) {
+	var teleErr *TelegramError
+	if errors.As(err, &teleErr) {
+		return teleErr, true
+	}
+	return nil, false
+}
+
+// newRequesterError converts unsuccessful API responses into TelegramErrors
+func newRequesterError(code int, description string, params *methods.ResponseParameters) error {
+	var parameters *ResponseParameters
+	if params != nil {
+		parameters = &ResponseParameters{
+			MigrateToChatID: params.MigrateToChatID,
+			RetryAfter:      params.RetryAfter,
+		}
+	}
+	return NewTelegramErrorWithParams(code, description, parameters, nil)
+}
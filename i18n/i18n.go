@@ -0,0 +1,332 @@
+// Package i18n provides message catalogs with plural rules and named
+// placeholders, and resolves the locale of Telegram users.
+//
+// Catalog files are named after their locale (en.json, ru.po, fa.json).
+// JSON catalogs map keys to texts; an object whose keys are all plural
+// categories holds plural forms, any other object is a namespace:
+//
+//	{
+//		"welcome": "Hello, {name}!",
+//		"cart": {
+//			"items": {"one": "{count} item", "other": "{count} items"}
+//		}
+//	}
+//
+// Texts are looked up with dotted keys ("cart.items"). The "count" argument
+// selects the plural form.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/erfjab/egobot/models"
+	"github.com/erfjab/egobot/state/storage"
+)
+
+// CountArg is the argument selecting the plural form
+const CountArg = "count"
+
+// preferenceKey is the storage data key holding a user's chosen locale
+const preferenceKey = "locale"
+
+// Args holds named placeholder values
+type Args map[string]interface{}
+
+// Message is a translated text with its plural forms.
+// Texts without plural forms are stored as Other.
+type Message map[PluralCategory]string
+
+// Translator holds the catalogs of all locales
+type Translator struct {
+	mu       sync.RWMutex
+	catalogs map[string]map[string]Message
+	fallback string
+	store    storage.BaseStorage
+}
+
+// New creates a translator falling back to the given locale
+func New(fallback string) *Translator {
+	return &Translator{
+		catalogs: make(map[string]map[string]Message),
+		fallback: normalizeLocale(fallback),
+	}
+}
+
+// Fallback returns the fallback locale
+func (t *Translator) Fallback() string {
+	return t.fallback
+}
+
+// SetStorage sets where user locale preferences are kept
+func (t *Translator) SetStorage(store storage.BaseStorage) {
+	t.mu.Lock()
+	t.store = store
+	t.mu.Unlock()
+}
+
+// Storage returns where user locale preferences are kept
+func (t *Translator) Storage() storage.BaseStorage {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.store
+}
+
+// Add adds or replaces a message of a locale
+func (t *Translator) Add(locale, key string, message Message) {
+	locale = normalizeLocale(locale)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	catalog, ok := t.catalogs[locale]
+	if !ok {
+		catalog = make(map[string]Message)
+		t.catalogs[locale] = catalog
+	}
+	catalog[key] = message
+}
+
+// AddText adds or replaces a text without plural forms
+func (t *Translator) AddText(locale, key, text string) {
+	t.Add(locale, key, Message{Other: text})
+}
+
+// Locales returns the locales with a catalog, sorted
+func (t *Translator) Locales() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	locales := make([]string, 0, len(t.catalogs))
+	for locale := range t.catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Has reports whether locale itself (without fallback) has a translation for key
+func (t *Translator) Has(locale, key string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, ok := t.catalogs[normalizeLocale(locale)][key]
+	return ok
+}
+
+// Supports reports whether a catalog exists for locale or its base language
+func (t *Translator) Supports(locale string) bool {
+	return t.match(locale) != ""
+}
+
+// match returns the catalog locale serving locale: the exact locale or its base language
+func (t *Translator) match(locale string) string {
+	locale = normalizeLocale(locale)
+	if locale == "" {
+		return ""
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if _, ok := t.catalogs[locale]; ok {
+		return locale
+	}
+	if base := baseLanguage(locale); base != locale {
+		if _, ok := t.catalogs[base]; ok {
+			return base
+		}
+	}
+	return ""
+}
+
+func (t *Translator) lookup(locale, key string) (Message, string, bool) {
+	candidates := []string{normalizeLocale(locale), baseLanguage(locale), t.fallback}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, candidate := range candidates {
+		if message, ok := t.catalogs[candidate][key]; ok {
+			return message, candidate, true
+		}
+	}
+	return nil, "", false
+}
+
+// Translate returns the text of key in locale, falling back to the base
+// language and then the fallback locale. Returns key if nothing is found.
+//
+// args are Args (or map[string]interface{}) and/or name, value pairs:
+//
+//	t.Translate("ru", "cart.items", "count", 3)
+//	t.Translate("en", "welcome", i18n.Args{"name": user.FirstName})
+func (t *Translator) Translate(locale, key string, args ...interface{}) string {
+	message, found, ok := t.lookup(locale, key)
+	if !ok {
+		return key
+	}
+	values := collectArgs(args)
+
+	text := message[Other]
+	if count, ok := values[CountArg]; ok {
+		if n, ok := toFloat(count); ok {
+			if form, ok := message[Plural(found, n)]; ok {
+				text = form
+			}
+		}
+	}
+	if text == "" {
+		for _, category := range []PluralCategory{One, Few, Many, Two, Zero} {
+			if form, ok := message[category]; ok {
+				text = form
+				break
+			}
+		}
+	}
+	return Format(text, values)
+}
+
+// Format replaces {name} placeholders with values. Unknown placeholders are kept.
+func Format(text string, values map[string]interface{}) string {
+	if len(values) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+	var builder strings.Builder
+	for {
+		open := strings.IndexByte(text, '{')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(text[open:], '}')
+		if end < 0 {
+			break
+		}
+		name := text[open+1 : open+end]
+		builder.WriteString(text[:open])
+		if value, ok := values[name]; ok {
+			builder.WriteString(fmt.Sprint(value))
+		} else {
+			builder.WriteString(text[open : open+end+1])
+		}
+		text = text[open+end+1:]
+	}
+	builder.WriteString(text)
+	return builder.String()
+}
+
+func collectArgs(args []interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	for i := 0; i < len(args); i++ {
+		switch v := args[i].(type) {
+		case Args:
+			for name, value := range v {
+				values[name] = value
+			}
+		case map[string]interface{}:
+			for name, value := range v {
+				values[name] = value
+			}
+		case string:
+			if i+1 < len(args) {
+				values[v] = args[i+1]
+				i++
+			}
+		}
+	}
+	return values
+}
+
+// UserLocale resolves the locale of a user: the preference stored with
+// SetUserLocale, then the user's Telegram language, then the fallback locale.
+// Locales without a catalog are skipped.
+func (t *Translator) UserLocale(ctx context.Context, user *models.User) string {
+	if user == nil {
+		return t.fallback
+	}
+	if preferred, err := t.PreferredLocale(ctx, user.ID); err == nil && preferred != "" {
+		if locale := t.match(preferred); locale != "" {
+			return locale
+		}
+	}
+	if locale := t.match(user.LanguageCode); locale != "" {
+		return locale
+	}
+	return t.fallback
+}
+
+// PreferredLocale returns the locale a user chose, or "" if none was stored
+func (t *Translator) PreferredLocale(ctx context.Context, userID int64) (string, error) {
+	store := t.Storage()
+	if store == nil {
+		return "", nil
+	}
+	data, err := store.GetData(ctx, preferenceStorageKey(userID))
+	if err != nil {
+		return "", err
+	}
+	locale, _ := data[preferenceKey].(string)
+	return locale, nil
+}
+
+// SetUserLocale stores the locale a user chose, overriding their Telegram language.
+// Pass "" to remove the preference.
+func (t *Translator) SetUserLocale(ctx context.Context, userID int64, locale string) error {
+	store := t.Storage()
+	if store == nil {
+		return fmt.Errorf("i18n: no storage set for locale preferences")
+	}
+	if locale == "" {
+		return store.ClearAll(ctx, preferenceStorageKey(userID))
+	}
+	return store.UpsertData(ctx, preferenceStorageKey(userID), map[string]interface{}{
+		preferenceKey: normalizeLocale(locale),
+	})
+}
+
+func preferenceStorageKey(userID int64) string {
+	return "i18n:" + strconv.FormatInt(userID, 10)
+}
+
+// normalizeLocale lowercases a locale and uses "-" as separator ("pt_BR" -> "pt-br")
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
+
+// baseLanguage returns the language part of a locale ("pt-br" -> "pt")
+func baseLanguage(locale string) string {
+	locale = normalizeLocale(locale)
+	if language, _, ok := strings.Cut(locale, "-"); ok {
+		return language
+	}
+	return locale
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,270 @@
+package i18n
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LoadDir loads every .json and .po catalog in dir, using file names as locales
+func (t *Translator) LoadDir(dir string) error {
+	return t.LoadFS(os.DirFS(dir), ".")
+}
+
+// LoadFS loads every .json and .po catalog in dir of fsys (e.g. an embed.FS),
+// using file names as locales
+func (t *Translator) LoadFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		ext := path.Ext(name)
+		if ext != ".json" && ext != ".po" {
+			continue
+		}
+		if err := t.loadFSFile(fsys, path.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFile loads a .json or .po catalog, using the file name as locale
+func (t *Translator) LoadFile(filename string) error {
+	return t.loadFSFile(os.DirFS(filepath.Dir(filename)), filepath.Base(filename))
+}
+
+func (t *Translator) loadFSFile(fsys fs.FS, name string) error {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	ext := path.Ext(name)
+	locale := strings.TrimSuffix(path.Base(name), ext)
+	switch ext {
+	case ".json":
+		err = t.LoadJSON(locale, file)
+	case ".po":
+		err = t.LoadPO(locale, file)
+	default:
+		return fmt.Errorf("i18n: unsupported catalog format %q", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("i18n: %s: %w", name, err)
+	}
+	return nil
+}
+
+// LoadJSON loads a JSON catalog for locale. See the package documentation for the format.
+func (t *Translator) LoadJSON(locale string, r io.Reader) error {
+	var root map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&root); err != nil {
+		return err
+	}
+	return t.loadJSONObject(locale, "", root)
+}
+
+func (t *Translator) loadJSONObject(locale, prefix string, object map[string]interface{}) error {
+	for name, value := range object {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		switch v := value.(type) {
+		case string:
+			t.AddText(locale, key, v)
+		case map[string]interface{}:
+			if message, ok := pluralMessage(v); ok {
+				t.Add(locale, key, message)
+				continue
+			}
+			if err := t.loadJSONObject(locale, key, v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("key %q: expected a string or an object", key)
+		}
+	}
+	return nil
+}
+
+// pluralMessage converts an object whose keys are all plural categories
+func pluralMessage(object map[string]interface{}) (Message, bool) {
+	if len(object) == 0 {
+		return nil, false
+	}
+	message := make(Message, len(object))
+	for name, value := range object {
+		text, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		switch category := PluralCategory(name); category {
+		case Zero, One, Two, Few, Many, Other:
+			message[category] = text
+		default:
+			return nil, false
+		}
+	}
+	return message, true
+}
+
+// LoadPO loads a gettext .po catalog for locale. msgid is used as key;
+// entries with msgctxt are keyed "msgctxt.msgid". Plural forms msgstr[n]
+// map to the locale's plural categories in gettext order.
+// Fuzzy and untranslated entries are skipped.
+func (t *Translator) LoadPO(locale string, r io.Reader) error {
+	var (
+		entry   poEntry
+		field   *string
+		plurals = pluralFor(locale).categories
+	)
+
+	flush := func() {
+		if !entry.fuzzy && entry.id != "" {
+			key := entry.id
+			if entry.context != "" {
+				key = entry.context + "." + entry.id
+			}
+			if message := entry.message(plurals); message != nil {
+				t.Add(locale, key, message)
+			}
+		}
+		entry = poEntry{}
+		field = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#,"):
+			if entry.started() {
+				flush()
+			}
+			entry.fuzzy = strings.Contains(line, "fuzzy")
+		case strings.HasPrefix(line, "#"):
+			// Comment
+		case strings.HasPrefix(line, `"`):
+			if field == nil {
+				return fmt.Errorf("line %d: unexpected string", lineNumber)
+			}
+			text, err := strconv.Unquote(line)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			*field += text
+		default:
+			keyword, rest, ok := strings.Cut(line, " ")
+			if !ok {
+				return fmt.Errorf("line %d: invalid line", lineNumber)
+			}
+			text, err := strconv.Unquote(strings.TrimSpace(rest))
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+
+			switch {
+			case keyword == "msgctxt":
+				if entry.id != "" || len(entry.strs) > 0 {
+					flush()
+				}
+				entry.context = text
+				field = &entry.context
+			case keyword == "msgid":
+				if entry.id != "" || len(entry.strs) > 0 {
+					flush()
+				}
+				entry.id = text
+				entry.hasID = true
+				field = &entry.id
+			case keyword == "msgid_plural":
+				entry.plural = true
+				field = new(string)
+			case keyword == "msgstr":
+				entry.strs = append(entry.strs, text)
+				field = &entry.strs[len(entry.strs)-1]
+			case strings.HasPrefix(keyword, "msgstr[") && strings.HasSuffix(keyword, "]"):
+				index, err := strconv.Atoi(keyword[len("msgstr[") : len(keyword)-1])
+				if err != nil || index < 0 {
+					return fmt.Errorf("line %d: invalid plural index", lineNumber)
+				}
+				for len(entry.strs) <= index {
+					entry.strs = append(entry.strs, "")
+				}
+				entry.strs[index] = text
+				field = &entry.strs[index]
+			default:
+				return fmt.Errorf("line %d: unknown keyword %q", lineNumber, keyword)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	flush()
+	return nil
+}
+
+type poEntry struct {
+	context string
+	id      string
+	hasID   bool
+	plural  bool
+	strs    []string
+	fuzzy   bool
+}
+
+func (e *poEntry) started() bool {
+	return e.context != "" || e.hasID
+}
+
+func (e *poEntry) message(plurals []PluralCategory) Message {
+	if !e.plural {
+		if len(e.strs) == 0 || e.strs[0] == "" {
+			return nil
+		}
+		return Message{Other: e.strs[0]}
+	}
+
+	message := make(Message)
+	for i, text := range e.strs {
+		if text == "" {
+			continue
+		}
+		category := Other
+		if i < len(plurals) {
+			category = plurals[i]
+		}
+		message[category] = text
+	}
+	if len(message) == 0 {
+		return nil
+	}
+	// The last gettext form also covers CLDR "other" (e.g. fractions in ru)
+	if _, ok := message[Other]; !ok {
+		if last := e.strs[len(e.strs)-1]; last != "" {
+			message[Other] = last
+		}
+	}
+	return message
+}
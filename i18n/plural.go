@@ -0,0 +1,110 @@
+package i18n
+
+import (
+	"math"
+	"sync"
+)
+
+// PluralCategory is a CLDR plural category
+type PluralCategory string
+
+const (
+	Zero  PluralCategory = "zero"
+	One   PluralCategory = "one"
+	Two   PluralCategory = "two"
+	Few   PluralCategory = "few"
+	Many  PluralCategory = "many"
+	Other PluralCategory = "other"
+)
+
+// PluralRule picks the plural category of a number
+type PluralRule func(n float64) PluralCategory
+
+type pluralEntry struct {
+	rule       PluralRule
+	categories []PluralCategory // In gettext msgstr[n] order
+}
+
+var (
+	pluralMu    sync.RWMutex
+	pluralRules = map[string]pluralEntry{
+		"en": {rule: pluralOneOther, categories: []PluralCategory{One, Other}},
+		"de": {rule: pluralOneOther, categories: []PluralCategory{One, Other}},
+		"es": {rule: pluralOneOther, categories: []PluralCategory{One, Other}},
+		"fa": {rule: pluralPersian, categories: []PluralCategory{One, Other}},
+		"fr": {rule: pluralFrench, categories: []PluralCategory{One, Other}},
+		"ru": {rule: pluralEastSlavic, categories: []PluralCategory{One, Few, Many}},
+		"uk": {rule: pluralEastSlavic, categories: []PluralCategory{One, Few, Many}},
+	}
+)
+
+// RegisterPluralRule sets the plural rule of a language.
+// categories lists the categories in the order of gettext msgstr[n] forms.
+func RegisterPluralRule(language string, rule PluralRule, categories ...PluralCategory) {
+	pluralMu.Lock()
+	defer pluralMu.Unlock()
+	if len(categories) == 0 {
+		categories = []PluralCategory{One, Other}
+	}
+	pluralRules[baseLanguage(language)] = pluralEntry{rule: rule, categories: categories}
+}
+
+// Plural returns the plural category of n in a language.
+// Languages without a rule use the English rule.
+func Plural(language string, n float64) PluralCategory {
+	return pluralFor(language).rule(n)
+}
+
+func pluralFor(language string) pluralEntry {
+	pluralMu.RLock()
+	defer pluralMu.RUnlock()
+	if entry, ok := pluralRules[baseLanguage(language)]; ok {
+		return entry
+	}
+	return pluralRules["en"]
+}
+
+func isInteger(n float64) bool {
+	return n == math.Trunc(n)
+}
+
+// pluralOneOther: one for 1, other for everything else (en, de, es)
+func pluralOneOther(n float64) PluralCategory {
+	if n == 1 {
+		return One
+	}
+	return Other
+}
+
+// pluralPersian: one for 1 and anything below 1, other otherwise (fa)
+func pluralPersian(n float64) PluralCategory {
+	if n >= 0 && n <= 1 {
+		return One
+	}
+	return Other
+}
+
+// pluralFrench: one for anything below 2, other otherwise (fr)
+func pluralFrench(n float64) PluralCategory {
+	if n >= 0 && n < 2 {
+		return One
+	}
+	return Other
+}
+
+// pluralEastSlavic: one, few, many for integers and other for fractions (ru, uk)
+func pluralEastSlavic(n float64) PluralCategory {
+	if !isInteger(n) {
+		return Other
+	}
+	i := int64(math.Abs(n))
+	mod10, mod100 := i%10, i%100
+	switch {
+	case mod10 == 1 && mod100 != 11:
+		return One
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return Few
+	default:
+		return Many
+	}
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/erfjab/egobot/core"
+	"github.com/erfjab/egobot/models"
+	"github.com/erfjab/egobot/state/storage"
+)
+
+// InFlightConfig configures in-flight callback deduplication
+type InFlightConfig struct {
+	Key      KeyFunc             // Who the callback belongs to (default ByUser)
+	Timeout  time.Duration       // Locks older than this are considered stale, and expire with expiring storages (default 1 minute)
+	Storage  storage.BaseStorage // Lock backend (default a new MemoryStorage)
+	OnReject RejectFunc          // Called for duplicate clicks (default Drop)
+}
+
+// InFlight returns a middleware that ignores a callback query while another
+// callback query with the same data from the same key is still being handled.
+// Other updates pass through.
+//
+// Updates are handled concurrently with PollingOptions.Async (the default),
+// so a second click can arrive while the first one is still running.
+func InFlight(config InFlightConfig) core.MiddlewareFunc {
+	if config.Key == nil {
+		config.Key = ByUser
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = time.Minute
+	}
+	if config.Storage == nil {
+		config.Storage = storage.NewMemoryStorage()
+	}
+	if config.OnReject == nil {
+		config.OnReject = Drop()
+	}
+
+	var mu sync.Mutex
+
+	// lock takes the lock held in data unless it is taken and not timed out.
+	// The time it was taken identifies this acquisition on release.
+	lock := func(data map[string]interface{}) (float64, bool) {
+		if since, ok := toFloat(data["since"]); ok {
+			if time.Since(time.UnixMilli(int64(since))) < config.Timeout {
+				return 0, false
+			}
+		}
+		since := float64(time.Now().UnixNano()) / float64(time.Millisecond)
+		data["since"] = since
+		return since, true
+	}
+
+	// owns reports whether data still holds the lock taken at since, rather
+	// than one taken over after it timed out
+	owns := func(data map[string]interface{}, since float64) bool {
+		held, ok := toFloat(data["since"])
+		return ok && held == since
+	}
+
+	// expire drops locks left behind by handlers that never released them,
+	// e.g. when the process stopped mid-update
+	expire := func(ctx context.Context, key string) error {
+		if store, ok := config.Storage.(storage.ExpiringStorage); ok {
+			return store.Expire(ctx, key, config.Timeout)
+		}
+		return nil
+	}
+
+	acquire := func(ctx context.Context, key string) (float64, bool, error) {
+		// Atomic storages make the lock hold across bot instances
+		if store, ok := config.Storage.(storage.AtomicStorage); ok {
+			var since float64
+			var acquired bool
+			err := store.Update(ctx, key, func(uc *storage.UserContext) error {
+				since, acquired = lock(uc.Data)
+				return nil
+			})
+			if err != nil || !acquired {
+				return 0, false, err
+			}
+			return since, true, expire(ctx, key)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		data, err := config.Storage.GetData(ctx, key)
+		if err != nil {
+			return 0, false, err
+		}
+		since, acquired := lock(data)
+		if !acquired {
+			return 0, false, nil
+		}
+		err = config.Storage.UpsertData(ctx, key, map[string]interface{}{
+			"since": since,
+		})
+		if err != nil {
+			return 0, false, err
+		}
+		return since, true, expire(ctx, key)
+	}
+
+	// release drops the lock taken at since, unless another click took it
+	// over in the meantime
+	release := func(ctx context.Context, key string, since float64) error {
+		// Atomic storages can't delete a key conditionally, so the lock is
+		// removed from the data and the key left to expire with it
+		if store, ok := config.Storage.(storage.AtomicStorage); ok {
+			return store.Update(ctx, key, func(uc *storage.UserContext) error {
+				if owns(uc.Data, since) {
+					delete(uc.Data, "since")
+				}
+				return nil
+			})
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		data, err := config.Storage.GetData(ctx, key)
+		if err != nil || !owns(data, since) {
+			return err
+		}
+		return config.Storage.ClearAll(ctx, key)
+	}
+
+	return func(bot *core.Bot, update *models.Update, ctx *core.Context, next core.NextFunc) {
+		if update.CallbackQuery == nil {
+			next()
+			return
+		}
+		owner, ok := config.Key(update)
+		if !ok {
+			next()
+			return
+		}
+
+		key := "inflight:" + owner + ":" + update.CallbackQuery.Data
+		since, acquired, err := acquire(context.Background(), key)
+		if err != nil {
+			log.Printf("In-flight storage error: %v", err)
+			next()
+			return
+		}
+		if !acquired {
+			config.OnReject(bot, update, ctx)
+			return
+		}
+		defer func() {
+			if err := release(context.Background(), key, since); err != nil {
+				log.Printf("In-flight storage error: %v", err)
+			}
+		}()
+		next()
+	}
+}
@@ -0,0 +1,92 @@
+// Package middleware provides reusable core.MiddlewareFunc implementations:
+// token-bucket rate limiting and in-flight callback deduplication.
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/erfjab/egobot/core"
+	"github.com/erfjab/egobot/models"
+)
+
+// KeyFunc returns the key an update is limited by.
+// Returning false lets the update pass without limiting.
+type KeyFunc func(update *models.Update) (string, bool)
+
+// RejectFunc is called instead of the handler when an update is rejected
+type RejectFunc func(bot *core.Bot, update *models.Update, ctx *core.Context)
+
+// ByUser limits per user (or sender chat for updates sent on behalf of a chat)
+func ByUser(update *models.Update) (string, bool) {
+	// The sender chat comes first: anonymous admins and channels all share
+	// the same placeholder user
+	if id, ok := update.EffectiveSenderID(); ok {
+		return "u" + strconv.FormatInt(id, 10), true
+	}
+	return "", false
+}
+
+// ByChat limits per chat
+func ByChat(update *models.Update) (string, bool) {
+	if chat := update.EffectiveChat(); chat != nil {
+		return "c" + strconv.FormatInt(chat.ID, 10), true
+	}
+	return "", false
+}
+
+// ByUserInChat limits per user within each chat
+func ByUserInChat(update *models.Update) (string, bool) {
+	user, ok := ByUser(update)
+	if !ok {
+		return "", false
+	}
+	chat, ok := ByChat(update)
+	if !ok {
+		return user, true
+	}
+	return user + ":" + chat, true
+}
+
+// Drop silently drops rejected updates
+func Drop() RejectFunc {
+	return func(*core.Bot, *models.Update, *core.Context) {}
+}
+
+// Alert answers rejected callback queries with an alert.
+// Other rejected updates are dropped silently.
+func Alert(text string) RejectFunc {
+	return func(_ *core.Bot, update *models.Update, ctx *core.Context) {
+		if update.CallbackQuery != nil {
+			_ = ctx.AnswerAlert(text)
+		}
+	}
+}
+
+// Notify answers rejected callback queries with a notification instead of an alert.
+// Other rejected updates are dropped silently.
+func Notify(text string) RejectFunc {
+	return func(_ *core.Bot, update *models.Update, ctx *core.Context) {
+		if update.CallbackQuery != nil {
+			_ = ctx.Answer(text)
+		}
+	}
+}
+
+// toFloat reads a number stored in storage data, which may come back as
+// float64 or a string depending on the storage backend
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
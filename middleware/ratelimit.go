@@ -0,0 +1,176 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/erfjab/egobot/core"
+	"github.com/erfjab/egobot/models"
+	"github.com/erfjab/egobot/state/storage"
+)
+
+var rateLimitScopes atomic.Int64
+
+// RateLimitConfig configures a token-bucket rate limiter
+type RateLimitConfig struct {
+	Rate     float64             // Tokens refilled per second
+	Burst    int                 // Bucket capacity (default 1)
+	Key      KeyFunc             // Bucket key (default ByUser)
+	Scope    string              // Bucket namespace; limiters sharing a scope share buckets (default unique per limiter)
+	Storage  storage.BaseStorage // Bucket backend (default a new MemoryStorage); expiring storages drop buckets once full again
+	OnReject RejectFunc          // Called for rejected updates (default Drop)
+}
+
+// RateLimit returns a token-bucket rate limiting middleware.
+//
+// Each limiter has its own buckets, so passing it to a single handler limits
+// that handler only. Use the same limiter on several handlers (or on a
+// HandlerGroup), or give limiters the same Scope, to limit them together.
+//
+// Example:
+//
+//	// At most one order per user every 5 seconds
+//	bot.OnCallbackStruct(&OrderCB{}, order, middleware.RateLimit(middleware.RateLimitConfig{
+//		Rate:     0.2,
+//		OnReject: middleware.Alert("Slow down"),
+//	}))
+func RateLimit(config RateLimitConfig) core.MiddlewareFunc {
+	limiter := NewRateLimiter(config)
+	return limiter.Middleware()
+}
+
+// RateLimiter is a token-bucket limiter keyed by KeyFunc
+type RateLimiter struct {
+	config RateLimitConfig
+	mu     sync.Mutex
+	now    func() time.Time
+}
+
+// NewRateLimiter creates a rate limiter, filling in config defaults
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	if config.Key == nil {
+		config.Key = ByUser
+	}
+	if config.Scope == "" {
+		config.Scope = "l" + strconv.FormatInt(rateLimitScopes.Add(1), 10)
+	}
+	if config.Storage == nil {
+		config.Storage = storage.NewMemoryStorage()
+	}
+	if config.OnReject == nil {
+		config.OnReject = Drop()
+	}
+	return &RateLimiter{config: config, now: time.Now}
+}
+
+// Middleware returns the limiter as a middleware
+func (l *RateLimiter) Middleware() core.MiddlewareFunc {
+	return func(bot *core.Bot, update *models.Update, ctx *core.Context, next core.NextFunc) {
+		key, ok := l.config.Key(update)
+		if !ok {
+			next()
+			return
+		}
+
+		allowed, err := l.Allow(context.Background(), key)
+		if err != nil {
+			log.Printf("Rate limiter storage error: %v", err)
+			next()
+			return
+		}
+		if !allowed {
+			l.config.OnReject(bot, update, ctx)
+			return
+		}
+		next()
+	}
+}
+
+// Allow takes a token from the bucket of key and reports whether one was available.
+// With a storage.AtomicStorage the bucket is updated atomically, so limiters
+// of several bot instances sharing the storage don't race. With a
+// storage.ExpiringStorage the bucket expires once it would be full again.
+func (l *RateLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	storageKey := "ratelimit:" + l.config.Scope + ":" + key
+	now := l.now()
+
+	var allowed bool
+	var tokens float64
+	take := func(data map[string]interface{}) {
+		allowed = l.take(data, now)
+		tokens, _ = toFloat(data["tokens"])
+	}
+
+	if store, ok := l.config.Storage.(storage.AtomicStorage); ok {
+		err := store.Update(ctx, storageKey, func(uc *storage.UserContext) error {
+			take(uc.Data)
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+		return allowed, l.expire(ctx, storageKey, tokens)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data, err := l.config.Storage.GetData(ctx, storageKey)
+	if err != nil {
+		return false, err
+	}
+	take(data)
+	err = l.config.Storage.UpsertData(ctx, storageKey, map[string]interface{}{
+		"tokens":  data["tokens"],
+		"updated": data["updated"],
+	})
+	if err != nil {
+		return false, err
+	}
+	return allowed, l.expire(ctx, storageKey, tokens)
+}
+
+// expire drops the bucket when it would be refilled to capacity, which is
+// the same as having no bucket
+func (l *RateLimiter) expire(ctx context.Context, storageKey string, tokens float64) error {
+	store, ok := l.config.Storage.(storage.ExpiringStorage)
+	if !ok || l.config.Rate <= 0 {
+		return nil
+	}
+	missing := float64(l.config.Burst) - tokens
+	ttl := time.Duration(missing / l.config.Rate * float64(time.Second)).Truncate(time.Millisecond)
+	return store.Expire(ctx, storageKey, ttl+time.Millisecond)
+}
+
+// take refills the bucket held in data and takes a token if one is available
+func (l *RateLimiter) take(data map[string]interface{}, now time.Time) bool {
+	capacity := float64(l.config.Burst)
+	tokens := capacity
+	if stored, ok := toFloat(data["tokens"]); ok {
+		tokens = stored
+		if updated, ok := toFloat(data["updated"]); ok {
+			elapsed := now.Sub(time.UnixMilli(int64(updated))).Seconds()
+			if elapsed > 0 {
+				tokens += elapsed * l.config.Rate
+			}
+		}
+		if tokens > capacity {
+			tokens = capacity
+		}
+	}
+
+	allowed := tokens >= 1
+	if allowed {
+		tokens--
+	}
+	data["tokens"] = tokens
+	data["updated"] = float64(now.UnixMilli())
+	return allowed
+}
@@ -0,0 +1,162 @@
+package models
+
+// Sender is whoever sent an update: a user, or a chat when the update was
+// sent on behalf of a channel, an anonymous group admin or a linked chat.
+type Sender struct {
+	User *User
+	Chat *Chat
+}
+
+// ID returns the sender chat ID if present, otherwise the user ID
+func (s *Sender) ID() int64 {
+	if s == nil {
+		return 0
+	}
+	if s.Chat != nil {
+		return s.Chat.ID
+	}
+	if s.User != nil {
+		return s.User.ID
+	}
+	return 0
+}
+
+// IsChat reports whether the update was sent on behalf of a chat
+func (s *Sender) IsChat() bool {
+	return s != nil && s.Chat != nil
+}
+
+// EffectiveMessage returns the message carried by the update, whatever its kind:
+// message, edited message, channel post, edited channel post, or the message
+// a callback button is attached to. Returns nil if there is none.
+func (u *Update) EffectiveMessage() *Message {
+	if u == nil {
+		return nil
+	}
+	switch {
+	case u.Message != nil:
+		return u.Message
+	case u.EditedMessage != nil:
+		return u.EditedMessage
+	case u.ChannelPost != nil:
+		return u.ChannelPost
+	case u.EditedChannelPost != nil:
+		return u.EditedChannelPost
+	case u.CallbackQuery != nil:
+		return u.CallbackQuery.Message
+	}
+	return nil
+}
+
+// EffectiveUser returns the user that caused the update.
+// Returns nil for updates without a user, such as channel posts and polls.
+// For messages sent on behalf of a chat this is Telegram's placeholder user
+// (GroupAnonymousBot, Channel_Bot), shared by every such chat; use
+// EffectiveSenderID to identify who sent the update.
+func (u *Update) EffectiveUser() *User {
+	if u == nil {
+		return nil
+	}
+	switch {
+	case u.Message != nil:
+		return u.Message.From
+	case u.EditedMessage != nil:
+		return u.EditedMessage.From
+	case u.ChannelPost != nil:
+		return u.ChannelPost.From
+	case u.EditedChannelPost != nil:
+		return u.EditedChannelPost.From
+	case u.CallbackQuery != nil:
+		return &u.CallbackQuery.From
+	case u.InlineQuery != nil:
+		return &u.InlineQuery.From
+	case u.ChosenInlineResult != nil:
+		return &u.ChosenInlineResult.From
+	case u.MessageReaction != nil:
+		return u.MessageReaction.User
+	case u.ShippingQuery != nil:
+		return &u.ShippingQuery.From
+	case u.PreCheckoutQuery != nil:
+		return &u.PreCheckoutQuery.From
+	case u.PollAnswer != nil:
+		return u.PollAnswer.User
+	case u.MyChatMember != nil:
+		return &u.MyChatMember.From
+	case u.ChatMember != nil:
+		return &u.ChatMember.From
+	case u.ChatJoinRequest != nil:
+		return &u.ChatJoinRequest.From
+	}
+	return nil
+}
+
+// EffectiveChat returns the chat the update belongs to.
+// Returns nil for updates outside of a chat, such as inline queries,
+// payment queries, polls and callbacks on inline messages.
+func (u *Update) EffectiveChat() *Chat {
+	if u == nil {
+		return nil
+	}
+	if msg := u.EffectiveMessage(); msg != nil {
+		return &msg.Chat
+	}
+	switch {
+	case u.MessageReaction != nil:
+		return &u.MessageReaction.Chat
+	case u.MyChatMember != nil:
+		return &u.MyChatMember.Chat
+	case u.ChatMember != nil:
+		return &u.ChatMember.Chat
+	case u.ChatJoinRequest != nil:
+		return &u.ChatJoinRequest.Chat
+	}
+	return nil
+}
+
+// EffectiveSender returns who sent the update, taking SenderChat into account
+// for messages sent on behalf of a chat. Returns nil if nobody can be resolved.
+func (u *Update) EffectiveSender() *Sender {
+	if u == nil {
+		return nil
+	}
+
+	var chat *Chat
+	switch {
+	case u.Message != nil:
+		chat = u.Message.SenderChat
+	case u.EditedMessage != nil:
+		chat = u.EditedMessage.SenderChat
+	case u.ChannelPost != nil:
+		chat = u.ChannelPost.SenderChat
+		if chat == nil {
+			chat = &u.ChannelPost.Chat
+		}
+	case u.EditedChannelPost != nil:
+		chat = u.EditedChannelPost.SenderChat
+		if chat == nil {
+			chat = &u.EditedChannelPost.Chat
+		}
+	case u.MessageReaction != nil:
+		chat = u.MessageReaction.ActorChat
+	case u.PollAnswer != nil:
+		chat = u.PollAnswer.VoterChat
+	}
+
+	user := u.EffectiveUser()
+	if user == nil && chat == nil {
+		return nil
+	}
+	return &Sender{User: user, Chat: chat}
+}
+
+// EffectiveSenderID returns the ID identifying who sent the update: the
+// sender chat for updates sent on behalf of a chat (anonymous group admins,
+// channels, linked chats), otherwise the user. Returns false if nobody can be
+// resolved.
+func (u *Update) EffectiveSenderID() (int64, bool) {
+	sender := u.EffectiveSender()
+	if sender == nil {
+		return 0, false
+	}
+	return sender.ID(), true
+}
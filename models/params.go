@@ -476,6 +476,14 @@ type DeleteMessagesParams struct {
 	MessageIDs []int       `json:"message_ids"`
 }
 
+// https://core.telegram.org/bots/api#sendchataction
+type SendChatActionParams struct {
+	BusinessConnectionID string      `json:"business_connection_id,omitempty"`
+	ChatID               interface{} `json:"chat_id"`
+	MessageThreadID      int64       `json:"message_thread_id,omitempty"`
+	Action               string      `json:"action"`
+}
+
 // https://core.telegram.org/bots/api#inputmedia
 type InputMedia interface{}
 
@@ -2,14 +2,22 @@ package models
 
 // https://core.telegram.org/bots/api#update
 type Update struct {
-	UpdateID           int64               `json:"update_id"`
-	Message            *Message            `json:"message,omitempty"`
-	EditedMessage      *Message            `json:"edited_message,omitempty"`
-	ChannelPost        *Message            `json:"channel_post,omitempty"`
-	EditedChannelPost  *Message            `json:"edited_channel_post,omitempty"`
-	CallbackQuery      *CallbackQuery      `json:"callback_query,omitempty"`
-	InlineQuery        *InlineQuery        `json:"inline_query,omitempty"`
-	ChosenInlineResult *ChosenInlineResult `json:"chosen_inline_result,omitempty"`
+	UpdateID           int64                   `json:"update_id"`
+	Message            *Message                `json:"message,omitempty"`
+	EditedMessage      *Message                `json:"edited_message,omitempty"`
+	ChannelPost        *Message                `json:"channel_post,omitempty"`
+	EditedChannelPost  *Message                `json:"edited_channel_post,omitempty"`
+	CallbackQuery      *CallbackQuery          `json:"callback_query,omitempty"`
+	InlineQuery        *InlineQuery            `json:"inline_query,omitempty"`
+	ChosenInlineResult *ChosenInlineResult     `json:"chosen_inline_result,omitempty"`
+	MessageReaction    *MessageReactionUpdated `json:"message_reaction,omitempty"`
+	ShippingQuery      *ShippingQuery          `json:"shipping_query,omitempty"`
+	PreCheckoutQuery   *PreCheckoutQuery       `json:"pre_checkout_query,omitempty"`
+	Poll               *Poll                   `json:"poll,omitempty"`
+	PollAnswer         *PollAnswer             `json:"poll_answer,omitempty"`
+	MyChatMember       *ChatMemberUpdated      `json:"my_chat_member,omitempty"`
+	ChatMember         *ChatMemberUpdated      `json:"chat_member,omitempty"`
+	ChatJoinRequest    *ChatJoinRequest        `json:"chat_join_request,omitempty"`
 }
 
 // https://core.telegram.org/bots/api#getupdates
@@ -84,6 +92,26 @@ type BotCommand struct {
 	Description string `json:"description"`
 }
 
+// https://core.telegram.org/bots/api#botcommandscope
+type BotCommandScope struct {
+	Type   BotCommandScopeType `json:"type"`
+	ChatID interface{}         `json:"chat_id,omitempty"`
+	UserID int64               `json:"user_id,omitempty"`
+}
+
+// https://core.telegram.org/bots/api#botcommandscope
+type BotCommandScopeType string
+
+const (
+	BotCommandScopeTypeDefault               BotCommandScopeType = "default"
+	BotCommandScopeTypeAllPrivateChats       BotCommandScopeType = "all_private_chats"
+	BotCommandScopeTypeAllGroupChats         BotCommandScopeType = "all_group_chats"
+	BotCommandScopeTypeAllChatAdministrators BotCommandScopeType = "all_chat_administrators"
+	BotCommandScopeTypeChat                  BotCommandScopeType = "chat"
+	BotCommandScopeTypeChatAdministrators    BotCommandScopeType = "chat_administrators"
+	BotCommandScopeTypeChatMember            BotCommandScopeType = "chat_member"
+)
+
 // https://core.telegram.org/bots/api#setmycommands
 type SetMyCommandsParams struct {
 	Commands     []BotCommand `json:"commands"`
@@ -190,6 +218,17 @@ type SetMessageReactionParams struct {
 	IsBig     bool          `json:"is_big,omitempty"`
 }
 
+// https://core.telegram.org/bots/api#messagereactionupdated
+type MessageReactionUpdated struct {
+	Chat        Chat           `json:"chat"`
+	MessageID   int64          `json:"message_id"`
+	User        *User          `json:"user,omitempty"`
+	ActorChat   *Chat          `json:"actor_chat,omitempty"`
+	Date        int64          `json:"date"`
+	OldReaction []ReactionType `json:"old_reaction"`
+	NewReaction []ReactionType `json:"new_reaction"`
+}
+
 // https://core.telegram.org/bots/api#reactiontype
 type ReactionType struct {
 	Type          string `json:"type"`
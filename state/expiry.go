@@ -0,0 +1,187 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/erfjab/egobot/state/storage"
+)
+
+// ErrExpiryUnsupported is returned when WithTTL is used on a storage that
+// doesn't implement storage.ExpiringStorage
+var ErrExpiryUnsupported = errors.New("storage does not support expiry")
+
+// StateOption configures a state change
+type StateOption func(*stateOptions)
+
+type stateOptions struct {
+	ttl    time.Duration
+	hasTTL bool
+}
+
+// WithTTL expires the user's state and data after ttl, overriding the TTL
+// configured for the state. WithTTL(0) keeps the user from expiring.
+func WithTTL(ttl time.Duration) StateOption {
+	return func(o *stateOptions) {
+		o.ttl = ttl
+		o.hasTTL = true
+	}
+}
+
+func collectStateOptions(opts []StateOption) stateOptions {
+	var options stateOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	return options
+}
+
+// Expired is a user context removed after its TTL
+type Expired struct {
+	Key   string
+	State *State // nil if no state was set
+	Data  map[string]interface{}
+}
+
+// ChatID returns the chat of a key built by the built-in key strategies.
+// For KeyByUser keys this is the user's private chat.
+func (e *Expired) ChatID() (int64, bool) {
+	return keyChatID(e.Key)
+}
+
+// UserID returns the user of a key built by KeyByUser, KeyByUserInChat or KeyByUserInTopic
+func (e *Expired) UserID() (int64, bool) {
+	return keyUserID(e.Key)
+}
+
+// ThreadID returns the forum topic of a key built by KeyByUserInTopic
+func (e *Expired) ThreadID() (int64, bool) {
+	return keyThreadID(e.Key)
+}
+
+// ExpiredHook runs when a user's context expires
+type ExpiredHook func(ctx context.Context, expired *Expired)
+
+// SetDefaultTTL sets the TTL of states without their own TTL (0 for none).
+// Storages that can't expire users (FileStorage, SQLStorage) ignore it.
+func (m *Manager) SetDefaultTTL(ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.defaultTTL = ttl
+}
+
+// SetStateTTL sets the TTL applied when a user enters state (0 for none).
+// Storages that can't expire users (FileStorage, SQLStorage) ignore it.
+// A nil state is ignored: clearing the state never sets a TTL.
+func (m *Manager) SetStateTTL(state *State, ttl time.Duration) {
+	if state == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stateTTLs == nil {
+		m.stateTTLs = make(map[string]time.Duration)
+	}
+	m.stateTTLs[state.Name] = ttl
+}
+
+// StateTTL returns the TTL applied when a user enters state
+func (m *Manager) StateTTL(state *State) time.Duration {
+	if state == nil {
+		return 0
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if ttl, ok := m.stateTTLs[state.Name]; ok {
+		return ttl
+	}
+	return m.defaultTTL
+}
+
+// OnStateExpired adds a hook run when a user's context expires.
+// Only storages implementing storage.ExpiryNotifier (MemoryStorage) report
+// expirations: RedisStorage drops expired users silently, and FileStorage
+// and SQLStorage never expire them.
+func (m *Manager) OnStateExpired(hook ExpiredHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expiredHooks = append(m.expiredHooks, hook)
+}
+
+// watchExpiry subscribes to expirations of store, once per store
+func (m *Manager) watchExpiry(store storage.BaseStorage) {
+	notifier, ok := store.(storage.ExpiryNotifier)
+	if !ok {
+		return
+	}
+	m.mu.Lock()
+	if m.watched[notifier] {
+		m.mu.Unlock()
+		return
+	}
+	if m.watched == nil {
+		m.watched = make(map[storage.ExpiryNotifier]bool)
+	}
+	m.watched[notifier] = true
+	m.mu.Unlock()
+
+	notifier.OnExpire(func(key string, uc *storage.UserContext) {
+		// Ignore storages replaced with SetStorage, and keys of other
+		// features sharing the storage
+		if m.GetStorage() != store || !m.IsKey(key) {
+			return
+		}
+		m.mu.RLock()
+		hooks := append([]ExpiredHook(nil), m.expiredHooks...)
+		m.mu.RUnlock()
+
+		expired := &Expired{Key: key, Data: uc.Data}
+		if uc.State != "" {
+			expired.State = NewState(uc.State)
+		}
+		for _, hook := range hooks {
+			hook(context.Background(), expired)
+		}
+	})
+}
+
+// resolveTTL returns the TTL of the user after entering state.
+// Storages that can't expire users ignore the configured TTLs, but an
+// explicit WithTTL fails before anything is stored.
+func (u *UserStateManager) resolveTTL(state *State, options stateOptions) (time.Duration, error) {
+	if state == nil {
+		return 0, nil
+	}
+	if _, ok := u.storage.(storage.ExpiringStorage); !ok {
+		if options.hasTTL && options.ttl > 0 {
+			return 0, ErrExpiryUnsupported
+		}
+		return 0, nil
+	}
+	ttl := options.ttl
+	if !options.hasTTL && u.manager != nil {
+		ttl = u.manager.StateTTL(state)
+	}
+	return ttl, nil
+}
+
+// expire sets or cancels (ttl 0) the expiry of the user
+func (u *UserStateManager) expire(ctx context.Context, ttl time.Duration) error {
+	store, ok := u.storage.(storage.ExpiringStorage)
+	if !ok {
+		return nil
+	}
+	return store.Expire(ctx, u.key, ttl)
+}
+
+// TTL returns the time left before the user's context expires, 0 if it doesn't
+func (u *UserStateManager) TTL(ctx context.Context) (time.Duration, error) {
+	store, ok := u.storage.(storage.ExpiringStorage)
+	if !ok {
+		return 0, nil
+	}
+	return store.TTL(ctx, u.key)
+}
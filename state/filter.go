@@ -2,6 +2,8 @@ package state
 
 import (
 	"context"
+	"fmt"
+	"path"
 )
 
 // Filter represents a state filter that can be used in handlers
@@ -10,6 +12,11 @@ type Filter struct {
 	ignoreState   bool
 	matchAny      bool // if true, matches any of the states; if false, requires exact match
 	allowNoState  bool // if true, allows execution when no state is set
+
+	// Set by InGroup, Match, NotIn, Not, And and Or; replaces the fields above in Check
+	match    func(current *State) bool
+	groups   []*StateGroup // Groups whose states may match, for GetStates
+	children []*Filter     // Filters whose states may match, for GetStates
 }
 
 // InState creates a state filter for specific states
@@ -37,6 +44,119 @@ func NoState() *Filter {
 	}
 }
 
+// InGroup creates a filter matching any state of the groups.
+// States added to a group later match too.
+// Usage: bot.AddHandler(filter, handler, state.InGroup(OrderStates))
+func InGroup(groups ...*StateGroup) *Filter {
+	return &Filter{
+		matchAny: true,
+		groups:   groups,
+		match: func(current *State) bool {
+			for _, group := range groups {
+				if group != nil && group.Contains(current) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Match creates a filter matching states whose name matches any of the
+// glob patterns (path.Match syntax), e.g. "Order.*" for every state of the
+// Order group. Panics on a malformed pattern.
+func Match(patterns ...string) *Filter {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			panic(fmt.Sprintf("state: invalid pattern %q: %v", pattern, err))
+		}
+	}
+	return &Filter{
+		matchAny: true,
+		match: func(current *State) bool {
+			if current == nil {
+				return false
+			}
+			for _, pattern := range patterns {
+				if ok, _ := path.Match(pattern, current.Name); ok {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// NotIn creates a filter matching any state except states.
+// Users without a state don't match; use Not(InState(...)) to include them.
+func NotIn(states ...*State) *Filter {
+	return &Filter{
+		matchAny: true,
+		match: func(current *State) bool {
+			return current != nil && !containsState(states, current)
+		},
+	}
+}
+
+// Not creates a filter matching exactly when filter doesn't, including
+// users without a state
+func Not(filter *Filter) *Filter {
+	return &Filter{
+		matchAny: true,
+		match: func(current *State) bool {
+			return !filter.Check(current)
+		},
+	}
+}
+
+// And creates a filter matching when all filters match. Nil filters are skipped.
+func And(filters ...*Filter) *Filter {
+	filters = nonNilFilters(filters)
+	return &Filter{
+		children: filters,
+		match: func(current *State) bool {
+			for _, filter := range filters {
+				if !filter.Check(current) {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// Or creates a filter matching when any of filters matches. Nil filters are skipped.
+func Or(filters ...*Filter) *Filter {
+	filters = nonNilFilters(filters)
+	for _, filter := range filters {
+		if filter.ignoreState {
+			return IgnoreState()
+		}
+	}
+	return &Filter{
+		matchAny: true,
+		children: filters,
+		match: func(current *State) bool {
+			for _, filter := range filters {
+				if filter.Check(current) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+func nonNilFilters(filters []*Filter) []*Filter {
+	kept := make([]*Filter, 0, len(filters))
+	for _, filter := range filters {
+		if filter != nil {
+			kept = append(kept, filter)
+		}
+	}
+	return kept
+}
+
 // Deprecated: Use InState instead
 func NewStateFilter(states ...*State) *Filter {
 	return InState(states...)
@@ -54,6 +174,13 @@ func NewNoStateFilter() *Filter {
 
 // Check checks if the given state matches the filter
 func (f *Filter) Check(currentState *State) bool {
+	if f.match != nil {
+		if currentState != nil && currentState.Name == "" {
+			currentState = nil
+		}
+		return f.match(currentState)
+	}
+
 	// If we ignore state, always return true
 	if f.ignoreState {
 		return true
@@ -89,9 +216,34 @@ func (f *Filter) MatchesAny() bool {
 	return f.matchAny
 }
 
-// GetStates returns the states this filter matches
+// GetStates returns the states this filter matches.
+// Filters built by Match, NotIn and Not can't list their states and return
+// only those known from the filters they were built from.
 func (f *Filter) GetStates() []*State {
-	return f.states
+	if f.match == nil {
+		return f.states
+	}
+
+	var candidates []*State
+	for _, group := range f.groups {
+		if group != nil {
+			candidates = append(candidates, group.States()...)
+		}
+	}
+	for _, child := range f.children {
+		candidates = append(candidates, child.GetStates()...)
+	}
+
+	var states []*State
+	seen := make(map[string]bool)
+	for _, state := range candidates {
+		if state == nil || seen[state.Name] || !f.Check(state) {
+			continue
+		}
+		seen[state.Name] = true
+		states = append(states, state)
+	}
+	return states
 }
 
 // IsIgnoreState returns true if this filter ignores state
@@ -101,6 +253,9 @@ func (f *Filter) IsIgnoreState() bool {
 
 // AllowNoState returns true if this filter allows no state
 func (f *Filter) AllowNoState() bool {
+	if f.match != nil {
+		return f.match(nil)
+	}
 	return f.allowNoState
 }
 
@@ -134,27 +289,17 @@ func (f *Filter) Middleware(stateManager *Manager) func(next func(ctx context.Co
 	}
 }
 
-// Or combines this filter with another using OR logic
+// Or combines this filter with another using OR logic (either must match)
 func (f *Filter) Or(other *Filter) *Filter {
-	combined := &Filter{
-		states:   append(f.states, other.states...),
-		matchAny: true,
-	}
-	if f.ignoreState || other.ignoreState {
-		combined.ignoreState = true
-	}
-	if f.allowNoState || other.allowNoState {
-		combined.allowNoState = true
-	}
-	return combined
+	return Or(f, other)
 }
 
 // And combines this filter with another using AND logic (both must match)
 func (f *Filter) And(other *Filter) *Filter {
-	return &Filter{
-		states:       f.states, // For AND, we keep only the first filter's states
-		ignoreState:  f.ignoreState && other.ignoreState,
-		matchAny:     false,
-		allowNoState: f.allowNoState && other.allowNoState,
-	}
+	return And(f, other)
+}
+
+// Not returns the negation of this filter
+func (f *Filter) Not() *Filter {
+	return Not(f)
 }
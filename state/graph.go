@@ -0,0 +1,342 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrIllegalTransition is matched by errors.Is for every TransitionError
+var ErrIllegalTransition = errors.New("illegal state transition")
+
+// GraphMode sets how a Graph reacts to undeclared transitions
+type GraphMode int
+
+const (
+	// GraphStrict rejects undeclared transitions with a TransitionError
+	GraphStrict GraphMode = iota
+	// GraphWarn logs undeclared transitions and lets them happen
+	GraphWarn
+)
+
+// noState is the graph node for "no state set"
+const noState = ""
+
+// TransitionError reports an undeclared transition between two states
+type TransitionError struct {
+	From string // "" when no state was set
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	from := e.From
+	if from == noState {
+		from = "<none>"
+	}
+	return fmt.Sprintf("illegal state transition from %s to %s", from, e.To)
+}
+
+// Is makes TransitionError match ErrIllegalTransition
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrIllegalTransition
+}
+
+// Transition is an allowed edge of a Graph. From is "" for entry transitions.
+type Transition struct {
+	From string
+	To   string
+}
+
+// TransitionHook runs when a user enters or leaves a state.
+// from and to are nil when no state is set on that side.
+// An error from an OnExit hook aborts the transition; an error from an
+// OnEnter hook is returned after the state was stored.
+type TransitionHook func(ctx context.Context, user *UserStateManager, from, to *State) error
+
+// Graph declares the allowed transitions between states.
+//
+// Only states that appear in the graph are checked: moving into a state the
+// graph does not know is always allowed. Clearing the state and staying in
+// the same state are always allowed.
+//
+// Example:
+//
+//	graph := state.NewGraph(state.GraphStrict).
+//		Entry(Order.Product).
+//		Allow(Order.Product, Order.Address).
+//		Allow(Order.Address, Order.Confirm, Order.Product)
+//	bot.StateManager.SetGraph(graph)
+type Graph struct {
+	mu      sync.RWMutex
+	mode    GraphMode
+	edges   map[string]map[string]bool
+	nodes   []string
+	known   map[string]bool
+	onEnter map[string][]TransitionHook
+	onExit  map[string][]TransitionHook
+	warn    func(error)
+}
+
+// NewGraph creates an empty transition graph
+func NewGraph(mode GraphMode) *Graph {
+	return &Graph{
+		mode:    mode,
+		edges:   make(map[string]map[string]bool),
+		known:   make(map[string]bool),
+		onEnter: make(map[string][]TransitionHook),
+		onExit:  make(map[string][]TransitionHook),
+		warn: func(err error) {
+			log.Printf("Warning: %v", err)
+		},
+	}
+}
+
+// Mode returns how undeclared transitions are handled
+func (g *Graph) Mode() GraphMode {
+	return g.mode
+}
+
+// SetWarnFunc sets the function receiving undeclared transitions in GraphWarn mode (default log.Printf)
+func (g *Graph) SetWarnFunc(warn func(error)) *Graph {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if warn != nil {
+		g.warn = warn
+	}
+	return g
+}
+
+func (g *Graph) addNode(name string) {
+	if name == noState || g.known[name] {
+		return
+	}
+	g.known[name] = true
+	g.nodes = append(g.nodes, name)
+}
+
+// Allow declares transitions from one state to each of the given states.
+// A nil from declares entry transitions, taken when no state is set.
+func (g *Graph) Allow(from *State, to ...*State) *Graph {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	fromName := stateName(from)
+	g.addNode(fromName)
+	targets, ok := g.edges[fromName]
+	if !ok {
+		targets = make(map[string]bool)
+		g.edges[fromName] = targets
+	}
+	for _, state := range to {
+		if state == nil {
+			continue
+		}
+		g.addNode(state.Name)
+		targets[state.Name] = true
+	}
+	return g
+}
+
+// Entry declares the states a flow may start in, when no state is set
+func (g *Graph) Entry(states ...*State) *Graph {
+	return g.Allow(nil, states...)
+}
+
+// Chain declares transitions through states in order: states[0] -> states[1] -> ...
+func (g *Graph) Chain(states ...*State) *Graph {
+	for i := 0; i+1 < len(states); i++ {
+		g.Allow(states[i], states[i+1])
+	}
+	return g
+}
+
+// OnEnter adds a hook run after a user enters state
+func (g *Graph) OnEnter(state *State, hook TransitionHook) *Graph {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.addNode(state.Name)
+	g.onEnter[state.Name] = append(g.onEnter[state.Name], hook)
+	return g
+}
+
+// OnExit adds a hook run before a user leaves state
+func (g *Graph) OnExit(state *State, hook TransitionHook) *Graph {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.addNode(state.Name)
+	g.onExit[state.Name] = append(g.onExit[state.Name], hook)
+	return g
+}
+
+// States returns the names of all states in the graph, in declaration order
+func (g *Graph) States() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]string(nil), g.nodes...)
+}
+
+// Transitions returns all declared transitions, sorted
+func (g *Graph) Transitions() []Transition {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	var transitions []Transition
+	for from, targets := range g.edges {
+		for to := range targets {
+			transitions = append(transitions, Transition{From: from, To: to})
+		}
+	}
+	sort.Slice(transitions, func(i, j int) bool {
+		if transitions[i].From != transitions[j].From {
+			return transitions[i].From < transitions[j].From
+		}
+		return transitions[i].To < transitions[j].To
+	})
+	return transitions
+}
+
+// Allowed reports whether moving from one state to another is allowed
+func (g *Graph) Allowed(from, to *State) bool {
+	fromName, toName := stateName(from), stateName(to)
+	if toName == noState || fromName == toName {
+		return true
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if !g.known[toName] {
+		return true
+	}
+	return g.edges[fromName][toName]
+}
+
+// Check validates a transition. Returns a TransitionError for undeclared
+// transitions in GraphStrict mode; in GraphWarn mode they are reported to
+// the warn function and nil is returned.
+func (g *Graph) Check(from, to *State) error {
+	if g.Allowed(from, to) {
+		return nil
+	}
+	err := &TransitionError{From: stateName(from), To: stateName(to)}
+	if g.mode == GraphWarn {
+		g.mu.RLock()
+		warn := g.warn
+		g.mu.RUnlock()
+		warn(err)
+		return nil
+	}
+	return err
+}
+
+// transition validates and performs a state change, running exit and enter hooks around apply
+func (g *Graph) transition(ctx context.Context, user *UserStateManager, from, to *State, apply func() error) error {
+	if err := g.Check(from, to); err != nil {
+		return err
+	}
+
+	fromName, toName := stateName(from), stateName(to)
+	changed := fromName != toName
+
+	g.mu.RLock()
+	exitHooks := append([]TransitionHook(nil), g.onExit[fromName]...)
+	enterHooks := append([]TransitionHook(nil), g.onEnter[toName]...)
+	g.mu.RUnlock()
+
+	if changed {
+		for _, hook := range exitHooks {
+			if err := hook(ctx, user, from, to); err != nil {
+				return err
+			}
+		}
+	}
+	if err := apply(); err != nil {
+		return err
+	}
+	if changed {
+		for _, hook := range enterHooks {
+			if err := hook(ctx, user, from, to); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DOT returns the graph in Graphviz DOT format
+func (g *Graph) DOT() string {
+	var builder strings.Builder
+	_ = g.WriteDOT(&builder, "states")
+	return builder.String()
+}
+
+// WriteDOT writes the graph as a Graphviz digraph named name
+func (g *Graph) WriteDOT(w io.Writer, name string) error {
+	if _, err := fmt.Fprintf(w, "digraph %s {\n", strconv.Quote(name)); err != nil {
+		return err
+	}
+	if err := g.WriteDOTBody(w); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+// WriteDOTBody writes the nodes and edges of the graph without the
+// surrounding digraph block, so other tools can add their own nodes
+func (g *Graph) WriteDOTBody(w io.Writer) error {
+	lines := []string{"\trankdir=LR;", "\tnode [shape=box, style=rounded];"}
+
+	if g.hasEntry() {
+		lines = append(lines, "\t\"<none>\" [shape=point, label=\"\"];")
+	}
+
+	g.mu.RLock()
+	for _, node := range g.nodes {
+		var notes []string
+		if len(g.onEnter[node]) > 0 {
+			notes = append(notes, "enter")
+		}
+		if len(g.onExit[node]) > 0 {
+			notes = append(notes, "exit")
+		}
+		label := node
+		if len(notes) > 0 {
+			label += "\\n(" + strings.Join(notes, ", ") + ")"
+		}
+		lines = append(lines, fmt.Sprintf("\t%s [label=\"%s\"];", strconv.Quote(node), escapeDOTLabel(label)))
+	}
+	g.mu.RUnlock()
+
+	for _, transition := range g.Transitions() {
+		from := transition.From
+		if from == noState {
+			from = "<none>"
+		}
+		lines = append(lines, fmt.Sprintf("\t%s -> %s;", strconv.Quote(from), strconv.Quote(transition.To)))
+	}
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func (g *Graph) hasEntry() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.edges[noState]) > 0
+}
+
+// escapeDOTLabel escapes quotes in a label that may contain \n line breaks
+func escapeDOTLabel(label string) string {
+	return strings.ReplaceAll(label, `"`, `\"`)
+}
+
+func stateName(state *State) string {
+	if state == nil {
+		return noState
+	}
+	return state.Name
+}
@@ -0,0 +1,290 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/erfjab/egobot/state/storage"
+)
+
+// HistoryKeyPrefix prefixes the storage key holding a user's history stack
+// kept by Push, e.g. "history:123". The stack is kept apart from the user's
+// data: GetData and ClearData don't see it, and ClearAll drops it.
+const HistoryKeyPrefix = "history:"
+
+const historyField = "entries"
+
+// DefaultHistoryLimit is the number of entries kept by Push by default
+const DefaultHistoryLimit = 20
+
+var (
+	// ErrHistoryEmpty is returned by Back when there is nothing to go back to
+	ErrHistoryEmpty = errors.New("state history is empty")
+	// ErrHistoryConflict is returned by Back and ResetTo when the history
+	// changed while going back, e.g. by a concurrent Push
+	ErrHistoryConflict = errors.New("state history changed concurrently")
+)
+
+// HistoryEntry is a state and the data the user had in it
+type HistoryEntry struct {
+	State *State // nil if no state was set
+	Data  map[string]interface{}
+}
+
+// SetHistoryLimit bounds the history stack of each user; the oldest entries
+// are dropped first. Pass 0 to restore DefaultHistoryLimit.
+func (m *Manager) SetHistoryLimit(limit int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.historyLimit = limit
+}
+
+// HistoryLimit returns the bound of the history stack of each user
+func (m *Manager) HistoryLimit() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.historyLimit <= 0 {
+		return DefaultHistoryLimit
+	}
+	return m.historyLimit
+}
+
+func (u *UserStateManager) historyLimit() int {
+	if u.manager == nil {
+		return DefaultHistoryLimit
+	}
+	return u.manager.HistoryLimit()
+}
+
+// Push saves the current state and a snapshot of the data on the user's
+// history stack, then enters state like SetState. Back returns to it:
+//
+//	userState.Push(ctx, Settings.Language)
+//	...
+//	userState.Back(ctx) // state and data as before Push
+func (u *UserStateManager) Push(ctx context.Context, state *State, opts ...StateOption) error {
+	ttl, err := u.resolveTTL(state, collectStateOptions(opts))
+	if err != nil {
+		return err
+	}
+	limit := u.historyLimit()
+	return u.transition(ctx, state, func() error {
+		var saved historyEntry
+		err := u.Update(ctx, func(uc *storage.UserContext) error {
+			saved = snapshot(uc)
+			uc.State = stateName(state)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = u.updateHistory(ctx, func(history []historyEntry) ([]historyEntry, error) {
+			history = append(history, saved)
+			if len(history) > limit {
+				history = history[len(history)-limit:]
+			}
+			return history, nil
+		})
+		if err != nil {
+			return err
+		}
+		return u.expireWithHistory(ctx, ttl)
+	})
+}
+
+// Back pops the last entry of the user's history stack and restores its
+// state and data, through the transition graph like SetState. Returns the
+// restored state, ErrHistoryEmpty, or ErrHistoryConflict if the stack
+// changed meanwhile.
+func (u *UserStateManager) Back(ctx context.Context, opts ...StateOption) (*State, error) {
+	history, err := u.loadHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, ErrHistoryEmpty
+	}
+	return u.restore(ctx, history, len(history)-1, opts)
+}
+
+// ResetTo returns to the last time the user was in state, dropping the
+// history after it and restoring the data it had then. If state is not in
+// the history, the history is cleared and state is entered keeping the data.
+func (u *UserStateManager) ResetTo(ctx context.Context, state *State, opts ...StateOption) error {
+	history, err := u.loadHistory(ctx)
+	if err != nil {
+		return err
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if stateName(history[i].entry().State) == stateName(state) {
+			_, err := u.restore(ctx, history, i, opts)
+			return err
+		}
+	}
+
+	ttl, err := u.resolveTTL(state, collectStateOptions(opts))
+	if err != nil {
+		return err
+	}
+	return u.transition(ctx, state, func() error {
+		if err := u.ClearHistory(ctx); err != nil {
+			return err
+		}
+		err := u.Update(ctx, func(uc *storage.UserContext) error {
+			uc.State = stateName(state)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return u.expire(ctx, ttl)
+	})
+}
+
+// restore truncates the history read as history at index and restores the
+// entry found there. The graph checks the state of that entry; if the stack
+// changed since it was read, nothing is restored and ErrHistoryConflict is
+// returned.
+func (u *UserStateManager) restore(ctx context.Context, history []historyEntry, index int, opts []StateOption) (*State, error) {
+	entry := history[index].entry()
+	ttl, err := u.resolveTTL(entry.State, collectStateOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	err = u.transition(ctx, entry.State, func() error {
+		err := u.updateHistory(ctx, func(current []historyEntry) ([]historyEntry, error) {
+			if !reflect.DeepEqual(current, history) {
+				return nil, ErrHistoryConflict
+			}
+			return current[:index], nil
+		})
+		if err != nil {
+			return err
+		}
+		err = u.Update(ctx, func(uc *storage.UserContext) error {
+			uc.State = stateName(entry.State)
+			uc.Data = entry.Data
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return u.expireWithHistory(ctx, ttl)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry.State, nil
+}
+
+// History returns the user's history stack, oldest entry first
+func (u *UserStateManager) History(ctx context.Context) ([]HistoryEntry, error) {
+	entries, err := u.loadHistory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]HistoryEntry, len(entries))
+	for i, entry := range entries {
+		history[i] = entry.entry()
+	}
+	return history, nil
+}
+
+// ClearHistory empties the user's history stack
+func (u *UserStateManager) ClearHistory(ctx context.Context) error {
+	return u.storage.ClearAll(ctx, u.historyKey())
+}
+
+// historyKey returns the storage key of the user's history stack
+func (u *UserStateManager) historyKey() string {
+	return HistoryKeyPrefix + u.key
+}
+
+// historyManager returns a manager of the history key, outside the graph
+func (u *UserStateManager) historyManager() *UserStateManager {
+	return &UserStateManager{key: u.historyKey(), storage: u.storage}
+}
+
+func (u *UserStateManager) loadHistory(ctx context.Context) ([]historyEntry, error) {
+	value, err := u.historyManager().GetDataValue(ctx, historyField)
+	if err != nil {
+		return nil, err
+	}
+	return decodeHistory(value), nil
+}
+
+// updateHistory replaces the history stack with the result of fn, atomically
+// like Update
+func (u *UserStateManager) updateHistory(ctx context.Context, fn func(history []historyEntry) ([]historyEntry, error)) error {
+	return u.historyManager().UpdateData(ctx, func(data map[string]interface{}) error {
+		history, err := fn(decodeHistory(data[historyField]))
+		if err != nil {
+			return err
+		}
+		if len(history) == 0 {
+			delete(data, historyField)
+			return nil
+		}
+		data[historyField] = encodeHistory(history)
+		return nil
+	})
+}
+
+// expireWithHistory sets or cancels the expiry of the user and its history
+func (u *UserStateManager) expireWithHistory(ctx context.Context, ttl time.Duration) error {
+	if err := u.expire(ctx, ttl); err != nil {
+		return err
+	}
+	return u.historyManager().expire(ctx, ttl)
+}
+
+// historyEntry is a HistoryEntry as stored in data
+type historyEntry map[string]interface{}
+
+func snapshot(uc *storage.UserContext) historyEntry {
+	return historyEntry{"state": uc.State, "data": copyData(uc.Data)}
+}
+
+func (e historyEntry) entry() HistoryEntry {
+	data, _ := e["data"].(map[string]interface{})
+	entry := HistoryEntry{Data: copyData(data)}
+	if name, _ := e["state"].(string); name != "" {
+		entry.State = NewState(name)
+	}
+	return entry
+}
+
+// decodeHistory reads the history stack stored in data
+func decodeHistory(value interface{}) []historyEntry {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	history := make([]historyEntry, 0, len(items))
+	for _, item := range items {
+		if entry, ok := item.(map[string]interface{}); ok {
+			history = append(history, historyEntry(entry))
+		}
+	}
+	return history
+}
+
+// encodeHistory stores the stack as plain values, so it reads the same
+// from every storage
+func encodeHistory(history []historyEntry) []interface{} {
+	items := make([]interface{}, len(history))
+	for i, entry := range history {
+		items[i] = map[string]interface{}(entry)
+	}
+	return items
+}
+
+func copyData(data map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		copied[k] = v
+	}
+	return copied
+}
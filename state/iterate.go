@@ -0,0 +1,151 @@
+package state
+
+import (
+	"context"
+	"errors"
+
+	"github.com/erfjab/egobot/state/storage"
+)
+
+var (
+	// ErrIterationUnsupported is returned when iterating users of a storage
+	// that doesn't implement storage.IterableStorage
+	ErrIterationUnsupported = errors.New("storage does not support iteration")
+	// ErrStopIteration can be returned by an iteration callback to stop
+	// early without an error
+	ErrStopIteration = errors.New("stop iteration")
+)
+
+func (m *Manager) iterable() (storage.IterableStorage, error) {
+	store, ok := m.GetStorage().(storage.IterableStorage)
+	if !ok {
+		return nil, ErrIterationUnsupported
+	}
+	return store, nil
+}
+
+// Each calls fn for every stored key matching match (path.Match syntax, ""
+// for all), e.g. "-100123:*" for every user of a chat under KeyByUserInChat.
+// Keys rejected by the key filter (see SetKeyFilter) are skipped.
+// Iteration stops at the first error, which is returned unless it is
+// ErrStopIteration.
+func (m *Manager) Each(ctx context.Context, match string, fn func(um *UserStateManager) error) error {
+	store, err := m.iterable()
+	if err != nil {
+		return err
+	}
+
+	cursor := ""
+	for {
+		keys, next, err := store.Scan(ctx, cursor, match, 0)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !m.IsKey(key) {
+				continue
+			}
+			if err := fn(m.ForKey(key)); err != nil {
+				if errors.Is(err, ErrStopIteration) {
+					return nil
+				}
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// EachInState calls fn for every user currently in state, e.g. to notify
+// everyone waiting for a payment:
+//
+//	err := manager.EachInState(ctx, Checkout.Payment, func(um *state.UserStateManager) error {
+//		chatID, ok := um.ChatID()
+//		...
+//	})
+func (m *Manager) EachInState(ctx context.Context, state *State, fn func(um *UserStateManager) error) error {
+	return m.EachInGroup(ctx, nil, func(um *UserStateManager, current *State) error {
+		if current == nil || !current.Equals(state) {
+			return nil
+		}
+		return fn(um)
+	})
+}
+
+// EachInGroup calls fn for every user in a state of group, with that state.
+// A nil group visits every user, with a nil state for users without one.
+func (m *Manager) EachInGroup(ctx context.Context, group *StateGroup, fn func(um *UserStateManager, state *State) error) error {
+	return m.Each(ctx, "", func(um *UserStateManager) error {
+		current, err := um.GetState(ctx)
+		if err != nil {
+			return err
+		}
+		if group != nil && !group.Contains(current) {
+			return nil
+		}
+		return fn(um, current)
+	})
+}
+
+// CountByState returns the number of users in each state; users without
+// a state are counted under "". Only keys accepted by the key filter (see
+// SetKeyFilter) are counted, so it reads every user instead of using
+// storage.IterableStorage.CountByState, which counts all stored keys.
+func (m *Manager) CountByState(ctx context.Context) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := m.EachInGroup(ctx, nil, func(_ *UserStateManager, current *State) error {
+		counts[stateName(current)]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// CountInGroup returns the number of users in a state of group.
+// A nil group counts every user, like EachInGroup.
+func (m *Manager) CountInGroup(ctx context.Context, group *StateGroup) (int, error) {
+	total := 0
+	err := m.EachInGroup(ctx, group, func(*UserStateManager, *State) error {
+		total++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// ClearStates clears the state of every user in one of states, or of every
+// user if none are given, e.g. after a deploy renamed states. Data is kept
+// and the transition graph is bypassed. Returns the number of users cleared.
+func (m *Manager) ClearStates(ctx context.Context, states ...*State) (int, error) {
+	cleared := 0
+	err := m.EachInGroup(ctx, nil, func(um *UserStateManager, current *State) error {
+		if current == nil || (len(states) > 0 && !containsState(states, current)) {
+			return nil
+		}
+		if err := um.storage.ClearState(ctx, um.key); err != nil {
+			return err
+		}
+		cleared++
+		return um.expire(ctx, 0)
+	})
+	return cleared, err
+}
+
+func containsState(states []*State, state *State) bool {
+	for _, s := range states {
+		if s != nil && s.Equals(state) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package state
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/erfjab/egobot/models"
+)
+
+// KeyStrategy derives the storage key of the state an update belongs to.
+// Returning false means the update has no state (e.g. no sender).
+type KeyStrategy func(update *models.Update) (string, bool)
+
+// KeyByUser keys state by user, shared across all chats: "<user>".
+// Updates sent on behalf of a chat are keyed by the sender chat.
+// This is the default strategy.
+func KeyByUser(update *models.Update) (string, bool) {
+	sender, ok := update.EffectiveSenderID()
+	if !ok {
+		return "", false
+	}
+	return UserKey(sender), true
+}
+
+// KeyByChat keys state by chat, shared by all its members: "<chat>"
+func KeyByChat(update *models.Update) (string, bool) {
+	if chat := update.EffectiveChat(); chat != nil {
+		return ChatKey(chat.ID), true
+	}
+	return "", false
+}
+
+// KeyByUserInChat keys state by user within each chat: "<chat>:<user>".
+// As with KeyByUser, the user of an update sent on behalf of a chat is the
+// sender chat.
+// Updates outside of a chat fall back to KeyByUser.
+func KeyByUserInChat(update *models.Update) (string, bool) {
+	sender, ok := update.EffectiveSenderID()
+	if !ok {
+		return "", false
+	}
+	chat := update.EffectiveChat()
+	if chat == nil {
+		return UserKey(sender), true
+	}
+	return UserInChatKey(chat.ID, sender), true
+}
+
+// KeyByUserInTopic keys state by user within each forum topic:
+// "<chat>:<thread>:<user>". Messages outside of topics use thread 0.
+// Updates outside of a chat fall back to KeyByUser.
+func KeyByUserInTopic(update *models.Update) (string, bool) {
+	sender, ok := update.EffectiveSenderID()
+	if !ok {
+		return "", false
+	}
+	chat := update.EffectiveChat()
+	if chat == nil {
+		return UserKey(sender), true
+	}
+	var thread int64
+	if msg := update.EffectiveMessage(); msg != nil && msg.IsTopicMessage {
+		thread = msg.MessageThreadID
+	}
+	return UserInTopicKey(chat.ID, thread, sender), true
+}
+
+// UserKey returns the key KeyByUser uses for a user
+func UserKey(userID int64) string {
+	return strconv.FormatInt(userID, 10)
+}
+
+// ChatKey returns the key KeyByChat uses for a chat
+func ChatKey(chatID int64) string {
+	return strconv.FormatInt(chatID, 10)
+}
+
+// UserInChatKey returns the key KeyByUserInChat uses for a user in a chat
+func UserInChatKey(chatID, userID int64) string {
+	return ChatKey(chatID) + ":" + UserKey(userID)
+}
+
+// UserInTopicKey returns the key KeyByUserInTopic uses for a user in a forum topic
+func UserInTopicKey(chatID, threadID, userID int64) string {
+	return ChatKey(chatID) + ":" + strconv.FormatInt(threadID, 10) + ":" + UserKey(userID)
+}
+
+// SetKeyStrategy sets how updates are mapped to state keys (default KeyByUser).
+// Pass nil to restore the default.
+func (m *Manager) SetKeyStrategy(strategy KeyStrategy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keyStrategy = strategy
+}
+
+// KeyStrategy returns how updates are mapped to state keys
+func (m *Manager) KeyStrategy() KeyStrategy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.keyStrategy == nil {
+		return KeyByUser
+	}
+	return m.keyStrategy
+}
+
+// KeyFor returns the state key of an update under the key strategy
+func (m *Manager) KeyFor(update *models.Update) (string, bool) {
+	return m.KeyStrategy()(update)
+}
+
+// ForKey returns the context manager of a raw storage key
+func (m *Manager) ForKey(key string) *UserStateManager {
+	return &UserStateManager{
+		key:     key,
+		storage: m.GetStorage(),
+		graph:   m.Graph(),
+		manager: m,
+	}
+}
+
+// ForChat returns the context manager KeyByChat resolves for a chat
+func (m *Manager) ForChat(chatID int64) *UserStateManager {
+	return m.ForKey(ChatKey(chatID))
+}
+
+// IsStateKey reports whether key was built by one of the built-in key
+// strategies: one to three numeric parts separated by ":". Keys other
+// features keep in the same storage ("i18n:<user>", "ratelimit:...") are not.
+func IsStateKey(key string) bool {
+	parts, ok := keyParts(key)
+	return ok && len(parts) <= 3
+}
+
+// SetKeyFilter sets which stored keys hold state, for iteration and expiry
+// hooks (default IsStateKey). Set it along with a custom key strategy.
+// Pass nil to restore the default.
+func (m *Manager) SetKeyFilter(filter func(key string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keyFilter = filter
+}
+
+// IsKey reports whether a stored key holds state, according to the key filter
+func (m *Manager) IsKey(key string) bool {
+	m.mu.RLock()
+	filter := m.keyFilter
+	m.mu.RUnlock()
+	if filter == nil {
+		return IsStateKey(key)
+	}
+	return filter(key)
+}
+
+// ForUserInChat returns the context manager KeyByUserInChat resolves for a user in a chat
+func (m *Manager) ForUserInChat(chatID, userID int64) *UserStateManager {
+	return m.ForKey(UserInChatKey(chatID, userID))
+}
+
+// ForUserInTopic returns the context manager KeyByUserInTopic resolves for a user in a forum topic
+func (m *Manager) ForUserInTopic(chatID, threadID, userID int64) *UserStateManager {
+	return m.ForKey(UserInTopicKey(chatID, threadID, userID))
+}
+
+// keyParts splits a key built by the key strategies into its numeric parts
+func keyParts(key string) ([]int64, bool) {
+	fields := strings.Split(key, ":")
+	parts := make([]int64, len(fields))
+	for i, field := range fields {
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+func keyChatID(key string) (int64, bool) {
+	parts, ok := keyParts(key)
+	if !ok {
+		return 0, false
+	}
+	return parts[0], true
+}
+
+func keyUserID(key string) (int64, bool) {
+	parts, ok := keyParts(key)
+	if !ok {
+		return 0, false
+	}
+	return parts[len(parts)-1], true
+}
+
+func keyThreadID(key string) (int64, bool) {
+	parts, ok := keyParts(key)
+	if !ok || len(parts) != 3 {
+		return 0, false
+	}
+	return parts[1], true
+}
+
+// ChatID returns the chat of a key built by the built-in key strategies.
+// For KeyByUser keys this is the user's private chat.
+func (u *UserStateManager) ChatID() (int64, bool) {
+	return keyChatID(u.key)
+}
+
+// UserID returns the user of a key built by KeyByUser, KeyByUserInChat or KeyByUserInTopic
+func (u *UserStateManager) UserID() (int64, bool) {
+	return keyUserID(u.key)
+}
+
+// ThreadID returns the forum topic of a key built by KeyByUserInTopic
+func (u *UserStateManager) ThreadID() (int64, bool) {
+	return keyThreadID(u.key)
+}
@@ -4,13 +4,26 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"sync"
+	"time"
 
+	"github.com/erfjab/egobot/models"
 	"github.com/erfjab/egobot/state/storage"
 )
 
 // Manager handles state management for users
 type Manager struct {
-	storage storage.BaseStorage
+	mu           sync.RWMutex
+	storage      storage.BaseStorage
+	graph        *Graph
+	defaultTTL   time.Duration
+	stateTTLs    map[string]time.Duration
+	expiredHooks []ExpiredHook
+	watched      map[storage.ExpiryNotifier]bool
+	keyStrategy  KeyStrategy
+	keyFilter    func(key string) bool
+	dataCodec    DataCodec
+	historyLimit int
 }
 
 // NewManager creates a new state manager with the given storage backend
@@ -18,23 +31,64 @@ func NewManager(store storage.BaseStorage) *Manager {
 	if store == nil {
 		store = storage.NewMemoryStorage()
 	}
-	return &Manager{
+	m := &Manager{
 		storage: store,
 	}
+	m.watchExpiry(store)
+	return m
 }
 
 // GetStorage returns the underlying storage
 func (m *Manager) GetStorage() storage.BaseStorage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.storage
 }
 
-// ForUser returns a user-specific context manager
+// SetStorage replaces the storage, keeping the graph, TTLs and hooks
+func (m *Manager) SetStorage(store storage.BaseStorage) {
+	if store == nil {
+		store = storage.NewMemoryStorage()
+	}
+	m.mu.Lock()
+	m.storage = store
+	m.mu.Unlock()
+	m.watchExpiry(store)
+}
+
+// SetGraph sets the transition graph enforced by SetState, ClearState,
+// ClearAll and state changes of UpdateContext. Pass nil to allow any
+// transition.
+func (m *Manager) SetGraph(graph *Graph) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.graph = graph
+}
+
+// Graph returns the transition graph, or nil if none is set
+func (m *Manager) Graph() *Graph {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.graph
+}
+
+// ForUser returns a user-specific context manager.
+// This is the key KeyByUser resolves; with another key strategy use ForKey,
+// ForChat, ForUserInChat or ForUserInTopic.
 func (m *Manager) ForUser(userID interface{}) *UserStateManager {
-	key := m.getUserKey(userID)
-	return &UserStateManager{
-		key:     key,
-		storage: m.storage,
+	return m.ForKey(m.getUserKey(userID))
+}
+
+// ForUpdate returns the context manager the key strategy resolves for the
+// update. Returns false if the update has no key (e.g. no sender).
+// Updates sent on behalf of a chat (anonymous admins, channels) resolve to
+// the sender chat, not to Telegram's placeholder user.
+func (m *Manager) ForUpdate(update *models.Update) (*UserStateManager, bool) {
+	key, ok := m.KeyFor(update)
+	if !ok {
+		return nil, false
 	}
+	return m.ForKey(key), true
 }
 
 // getUserKey converts various user ID types to string key
@@ -57,6 +111,8 @@ func (m *Manager) getUserKey(userID interface{}) string {
 type UserStateManager struct {
 	key     string
 	storage storage.BaseStorage
+	graph   *Graph
+	manager *Manager
 }
 
 // GetContext retrieves the complete user context
@@ -77,16 +133,48 @@ func (u *UserStateManager) GetState(ctx context.Context) (*State, error) {
 }
 
 // SetState sets the user's state
-func (u *UserStateManager) SetState(ctx context.Context, state *State) error {
-	if state == nil {
-		return u.storage.ClearState(ctx, u.key)
+// With a transition graph set, undeclared transitions return a TransitionError
+// in strict mode and the state's OnExit/OnEnter hooks run around the change.
+// The user expires after the state's TTL, or the one given with WithTTL:
+//
+//	userState.SetState(ctx, Order.Address, state.WithTTL(10*time.Minute))
+func (u *UserStateManager) SetState(ctx context.Context, state *State, opts ...StateOption) error {
+	ttl, err := u.resolveTTL(state, collectStateOptions(opts))
+	if err != nil {
+		return err
 	}
-	return u.storage.SetState(ctx, u.key, state.Name)
+	return u.transition(ctx, state, func() error {
+		if state == nil {
+			if err := u.storage.ClearState(ctx, u.key); err != nil {
+				return err
+			}
+		} else if err := u.storage.SetState(ctx, u.key, state.Name); err != nil {
+			return err
+		}
+		return u.expire(ctx, ttl)
+	})
 }
 
-// ClearState clears the user's state
+// ClearState clears the user's state and cancels its expiry
 func (u *UserStateManager) ClearState(ctx context.Context) error {
-	return u.storage.ClearState(ctx, u.key)
+	return u.transition(ctx, nil, func() error {
+		if err := u.storage.ClearState(ctx, u.key); err != nil {
+			return err
+		}
+		return u.expire(ctx, 0)
+	})
+}
+
+// transition applies a state change through the transition graph, if any
+func (u *UserStateManager) transition(ctx context.Context, to *State, apply func() error) error {
+	if u.graph == nil {
+		return apply()
+	}
+	from, err := u.GetState(ctx)
+	if err != nil {
+		return err
+	}
+	return u.graph.transition(ctx, u, from, to, apply)
 }
 
 // GetData retrieves the user's data
@@ -118,18 +206,100 @@ func (u *UserStateManager) ClearData(ctx context.Context) error {
 	return u.storage.ClearData(ctx, u.key)
 }
 
-// UpdateContext updates the complete user context
-func (u *UserStateManager) UpdateContext(ctx context.Context, state *State, data map[string]interface{}) error {
-	stateName := ""
-	if state != nil {
-		stateName = state.Name
+// UpdateContext updates the complete user context.
+// A nil state keeps the current state and its expiry, and only merges data
+// without going through the transition graph.
+func (u *UserStateManager) UpdateContext(ctx context.Context, state *State, data map[string]interface{}, opts ...StateOption) error {
+	if state == nil {
+		return u.storage.UpsertContext(ctx, u.key, "", data)
+	}
+	ttl, err := u.resolveTTL(state, collectStateOptions(opts))
+	if err != nil {
+		return err
+	}
+	return u.transition(ctx, state, func() error {
+		if err := u.storage.UpsertContext(ctx, u.key, state.Name, data); err != nil {
+			return err
+		}
+		return u.expire(ctx, ttl)
+	})
+}
+
+// Update reads, modifies and writes the user's context atomically when the
+// storage implements storage.AtomicStorage (all built-in storages do), so
+// concurrent updates of the same user don't lose writes. Other storages
+// read and write in separate calls: the new data is merged in one write and
+// removed keys are deleted afterwards with storage.DataDeleter, or set to
+// nil if the storage doesn't implement it.
+// State changes made by fn bypass the transition graph and TTLs.
+func (u *UserStateManager) Update(ctx context.Context, fn storage.UpdateFunc) error {
+	if store, ok := u.storage.(storage.AtomicStorage); ok {
+		return store.Update(ctx, u.key, fn)
+	}
+
+	uc, err := u.storage.GetContext(ctx, u.key)
+	if err != nil {
+		return err
+	}
+	previous := uc.State
+	previousKeys := make([]string, 0, len(uc.Data))
+	for k := range uc.Data {
+		previousKeys = append(previousKeys, k)
+	}
+	if err := fn(uc); err != nil {
+		return err
+	}
+
+	if uc.State == "" && previous != "" {
+		if err := u.storage.ClearState(ctx, u.key); err != nil {
+			return err
+		}
+	}
+	if err := u.storage.UpsertContext(ctx, u.key, uc.State, uc.Data); err != nil {
+		return err
+	}
+
+	var removed []string
+	for _, k := range previousKeys {
+		if _, ok := uc.Data[k]; !ok {
+			removed = append(removed, k)
+		}
 	}
-	return u.storage.UpsertContext(ctx, u.key, stateName, data)
+	if len(removed) == 0 {
+		return nil
+	}
+	if deleter, ok := u.storage.(storage.DataDeleter); ok {
+		return deleter.DeleteData(ctx, u.key, removed...)
+	}
+	tombstones := make(map[string]interface{}, len(removed))
+	for _, k := range removed {
+		tombstones[k] = nil
+	}
+	return u.storage.UpsertData(ctx, u.key, tombstones)
+}
+
+// UpdateData modifies the user's data in place, atomically (see Update):
+//
+//	err := userState.UpdateData(ctx, func(data map[string]interface{}) error {
+//		count, _ := data["count"].(float64)
+//		data["count"] = count + 1
+//		return nil
+//	})
+func (u *UserStateManager) UpdateData(ctx context.Context, fn func(data map[string]interface{}) error) error {
+	return u.Update(ctx, func(uc *storage.UserContext) error {
+		return fn(uc.Data)
+	})
 }
 
-// ClearAll clears all state and data for the user
+// ClearAll clears all state, data and history for the user.
+// Like ClearState, it goes through the transition graph to no state.
 func (u *UserStateManager) ClearAll(ctx context.Context) error {
-	return u.storage.ClearAll(ctx, u.key)
+	return u.transition(ctx, nil, func() error {
+		if err := u.storage.ClearAll(ctx, u.key); err != nil {
+			return err
+		}
+		return u.ClearHistory(ctx)
+	})
 }
 
 // Key returns the user's storage key
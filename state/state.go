@@ -37,6 +37,7 @@ func (s *State) Equals(other interface{}) bool {
 type StateGroup struct {
 	name   string
 	states map[string]*State
+	order  []*State
 }
 
 // NewStateGroup creates a new state group with the given name
@@ -51,6 +52,15 @@ func NewStateGroup(name string) *StateGroup {
 func (sg *StateGroup) Add(stateName string) *State {
 	fullName := fmt.Sprintf("%s.%s", sg.name, stateName)
 	state := NewState(fullName)
+	if _, ok := sg.states[stateName]; !ok {
+		sg.order = append(sg.order, state)
+	} else {
+		for i, s := range sg.order {
+			if s.Name == fullName {
+				sg.order[i] = state
+			}
+		}
+	}
 	sg.states[stateName] = state
 	return state
 }
@@ -66,6 +76,34 @@ func (sg *StateGroup) GetStates() map[string]*State {
 	return sg.states
 }
 
+// States returns the states of the group in the order they were added
+func (sg *StateGroup) States() []*State {
+	return append([]*State(nil), sg.order...)
+}
+
+// Filter returns a state filter matching any state of the group (see
+// InGroup), e.g. to bind a "back" button in every step of a wizard:
+//
+//	bot.AddHandler(backFilter, handleBack, Checkout.Filter())
+//
+// Handler options also accept the group itself.
+func (sg *StateGroup) Filter() *Filter {
+	return InGroup(sg)
+}
+
+// Contains reports whether state belongs to the group
+func (sg *StateGroup) Contains(state *State) bool {
+	if state == nil {
+		return false
+	}
+	for _, s := range sg.states {
+		if s.Name == state.Name {
+			return true
+		}
+	}
+	return false
+}
+
 // Name returns the group name
 func (sg *StateGroup) Name() string {
 	return sg.name
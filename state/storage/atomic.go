@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// maxUpdateRetries bounds compare-and-swap retries of Update
+const maxUpdateRetries = 100
+
+// ErrUpdateConflict is returned by Update when the context kept changing
+// concurrently and the update could not be applied
+var ErrUpdateConflict = errors.New("too many concurrent updates")
+
+// UpdateFunc modifies a copy of a user context in place; Data is never nil.
+// Returning an error aborts the update. It may run more than once when the
+// context changes concurrently, so it should have no side effects.
+type UpdateFunc func(uc *UserContext) error
+
+// AtomicStorage is implemented by storages that can read, modify and write
+// a user context atomically
+type AtomicStorage interface {
+	BaseStorage
+	// Update applies fn to the context of key and stores the result, unless
+	// another write happened in between, in which case fn is retried
+	Update(ctx context.Context, key string, fn UpdateFunc) error
+}
+
+// DataDeleter is implemented by storages that can remove single data keys
+type DataDeleter interface {
+	// DeleteData removes fields from the data of key
+	DeleteData(ctx context.Context, key string, fields ...string) error
+}
@@ -42,3 +42,12 @@ type BaseStorage interface {
 	// Close closes the storage connection (if applicable)
 	Close() error
 }
+
+// copyContext returns a copy of uc with its own data map
+func copyContext(uc *UserContext) *UserContext {
+	data := make(map[string]interface{}, len(uc.Data))
+	for k, v := range uc.Data {
+		data[k] = v
+	}
+	return &UserContext{State: uc.State, Data: data}
+}
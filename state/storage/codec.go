@@ -0,0 +1,383 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Codec serializes UserContext.Data for storages that keep it as bytes
+type Codec interface {
+	Name() string
+	Encode(data map[string]interface{}) ([]byte, error)
+	Decode(raw []byte) (map[string]interface{}, error)
+}
+
+var (
+	// JSONCodec stores data as JSON. Numbers decode as float64.
+	JSONCodec Codec = jsonCodec{}
+	// MsgpackCodec stores data as MessagePack. Integers decode as int64
+	// (uint64 above math.MaxInt64), floats as float64 and binary as []byte.
+	MsgpackCodec Codec = msgpackCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Encode(data map[string]interface{}) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+func (jsonCodec) Decode(raw []byte) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	if len(raw) == 0 {
+		return data, nil
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	return data, nil
+}
+
+var errMsgpackShort = errors.New("msgpack: unexpected end of data")
+
+// msgpackCodec is a minimal MessagePack implementation covering the types
+// found in user data. Structs and other types are converted through JSON.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Encode(data map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := msgpackEncode(&buf, reflect.ValueOf(data)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Decode(raw []byte) (map[string]interface{}, error) {
+	if len(raw) == 0 {
+		return make(map[string]interface{}), nil
+	}
+	decoder := &msgpackDecoder{data: raw}
+	value, err := decoder.decode()
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return make(map[string]interface{}), nil
+	}
+	data, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("msgpack: expected a map, got %T", value)
+	}
+	return data, nil
+}
+
+func msgpackEncode(buf *bytes.Buffer, v reflect.Value) error {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		buf.WriteByte(0xc0)
+	case reflect.Bool:
+		if v.Bool() {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		msgpackWriteInt(buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u <= math.MaxInt64 {
+			msgpackWriteInt(buf, int64(u))
+		} else {
+			buf.WriteByte(0xcf)
+			binary.Write(buf, binary.BigEndian, u)
+		}
+	case reflect.Float32:
+		buf.WriteByte(0xca)
+		binary.Write(buf, binary.BigEndian, float32(v.Float()))
+	case reflect.Float64:
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, v.Float())
+	case reflect.String:
+		msgpackWriteString(buf, v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			raw := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(raw), v)
+			msgpackWriteHeader(buf, len(raw), 0, 0xc4, 0xc5, 0xc6)
+			buf.Write(raw)
+			return nil
+		}
+		msgpackWriteHeader(buf, v.Len(), 0x90, 0, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			if err := msgpackEncode(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return msgpackEncodeJSON(buf, v)
+		}
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		msgpackWriteHeader(buf, v.Len(), 0x80, 0, 0xde, 0xdf)
+		iter := v.MapRange()
+		for iter.Next() {
+			msgpackWriteString(buf, iter.Key().String())
+			if err := msgpackEncode(buf, iter.Value()); err != nil {
+				return err
+			}
+		}
+	default:
+		return msgpackEncodeJSON(buf, v)
+	}
+	return nil
+}
+
+// msgpackEncodeJSON encodes values without a direct mapping (structs, maps
+// with non-string keys) as their JSON representation would decode
+func msgpackEncodeJSON(buf *bytes.Buffer, v reflect.Value) error {
+	raw, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Errorf("msgpack: %w", err)
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return fmt.Errorf("msgpack: %w", err)
+	}
+	return msgpackEncode(buf, reflect.ValueOf(generic))
+}
+
+func msgpackWriteInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		buf.WriteByte(byte(n))
+	case n < 0 && n >= -32:
+		buf.WriteByte(byte(int8(n)))
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(n)))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(n))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+func msgpackWriteString(buf *bytes.Buffer, s string) {
+	msgpackWriteHeader(buf, len(s), 0xa0, 0xd9, 0xda, 0xdb)
+	buf.WriteString(s)
+}
+
+// msgpackWriteHeader writes a length header: fix (up to 15, or 31 for
+// strings) when fix is set, then 8 bit (when b8 is set), 16 and 32 bit forms
+func msgpackWriteHeader(buf *bytes.Buffer, n int, fix, b8, b16, b32 byte) {
+	fixMax := 15
+	if fix == 0xa0 {
+		fixMax = 31
+	}
+	switch {
+	case fix != 0 && n <= fixMax:
+		buf.WriteByte(fix | byte(n))
+	case b8 != 0 && n <= math.MaxUint8:
+		buf.WriteByte(b8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(b16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(b32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+type msgpackDecoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, errMsgpackShort
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *msgpackDecoder) decode() (interface{}, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	tag := b[0]
+
+	switch {
+	case tag <= 0x7f:
+		return int64(tag), nil
+	case tag >= 0xe0:
+		return int64(int8(tag)), nil
+	case tag&0xf0 == 0x80:
+		return d.decodeMap(int(tag & 0x0f))
+	case tag&0xf0 == 0x90:
+		return d.decodeArray(int(tag & 0x0f))
+	case tag&0xe0 == 0xa0:
+		return d.decodeString(int(tag & 0x1f))
+	}
+
+	switch tag {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (tag - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (tag - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case 0xd0:
+		n, err := d.uint(1)
+		return int64(int8(n)), err
+	case 0xd1:
+		n, err := d.uint(2)
+		return int64(int16(n)), err
+	case 0xd2:
+		n, err := d.uint(4)
+		return int64(int32(n)), err
+	case 0xd3:
+		n, err := d.uint(8)
+		return int64(n), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (tag - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeString(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (tag - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (tag - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x", tag)
+}
+
+func (d *msgpackDecoder) decodeString(n int) (interface{}, error) {
+	raw, err := d.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+func (d *msgpackDecoder) decodeArray(n int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errMsgpackShort
+	}
+	items := make([]interface{}, n)
+	for i := range items {
+		item, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n int) (interface{}, error) {
+	if n > len(d.data)-d.pos {
+		return nil, errMsgpackShort
+	}
+	object := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(string)
+		if !ok {
+			name = fmt.Sprint(key)
+		}
+		value, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		object[name] = value
+	}
+	return object, nil
+}
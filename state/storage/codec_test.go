@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func msgpackRoundTrip(t *testing.T, data map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	raw, err := MsgpackCodec.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode(%v) error: %v", data, err)
+	}
+	decoded, err := MsgpackCodec.Decode(raw)
+	if err != nil {
+		t.Fatalf("Decode(% x) error: %v", raw, err)
+	}
+	return decoded
+}
+
+func TestMsgpackIntegers(t *testing.T) {
+	values := []int64{
+		0, 1, 127, 128, 255, 256,
+		-1, -32, -33, -128, -129,
+		math.MaxInt16, math.MaxInt16 + 1, math.MinInt16, math.MinInt16 - 1,
+		math.MaxInt32, math.MaxInt32 + 1, math.MinInt32, math.MinInt32 - 1,
+		math.MaxInt64, math.MinInt64,
+	}
+	for _, value := range values {
+		got := msgpackRoundTrip(t, map[string]interface{}{"n": value})["n"]
+		if got != value {
+			t.Errorf("round trip of %d = %#v", value, got)
+		}
+	}
+}
+
+func TestMsgpackIntegerKinds(t *testing.T) {
+	data := map[string]interface{}{
+		"int":    int(-5),
+		"int8":   int8(-100),
+		"int16":  int16(1000),
+		"int32":  int32(-70000),
+		"uint8":  uint8(200),
+		"uint16": uint16(60000),
+		"uint32": uint32(math.MaxUint32),
+		"uint64": uint64(math.MaxInt64),
+	}
+	want := map[string]interface{}{
+		"int":    int64(-5),
+		"int8":   int64(-100),
+		"int16":  int64(1000),
+		"int32":  int64(-70000),
+		"uint8":  int64(200),
+		"uint16": int64(60000),
+		"uint32": int64(math.MaxUint32),
+		"uint64": int64(math.MaxInt64),
+	}
+	if got := msgpackRoundTrip(t, data); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %#v, want %#v", got, want)
+	}
+}
+
+func TestMsgpackLargeUint64(t *testing.T) {
+	values := []uint64{math.MaxInt64 + 1, math.MaxUint64}
+	for _, value := range values {
+		got := msgpackRoundTrip(t, map[string]interface{}{"n": value})["n"]
+		if got != value {
+			t.Errorf("round trip of %d = %#v, want uint64", value, got)
+		}
+	}
+}
+
+func TestMsgpackBinary(t *testing.T) {
+	for _, size := range []int{0, 1, 255, 256, 65535, 65536} {
+		value := bytes.Repeat([]byte{0xab}, size)
+		got, ok := msgpackRoundTrip(t, map[string]interface{}{"b": value})["b"].([]byte)
+		if !ok || !bytes.Equal(got, value) {
+			t.Errorf("round trip of %d bytes = %T of %d bytes", size, got, len(got))
+		}
+	}
+}
+
+func TestMsgpackStringHeaders(t *testing.T) {
+	tests := []struct {
+		size int
+		tag  byte
+	}{
+		{0, 0xa0},
+		{31, 0xbf},
+		{32, 0xd9},
+		{255, 0xd9},
+		{256, 0xda},
+		{65535, 0xda},
+		{65536, 0xdb},
+	}
+	for _, tt := range tests {
+		value := strings.Repeat("x", tt.size)
+		raw, err := MsgpackCodec.Encode(map[string]interface{}{"s": value})
+		if err != nil {
+			t.Fatalf("Encode error: %v", err)
+		}
+		// fixmap(1), fixstr "s", then the value
+		if tag := raw[3]; tag != tt.tag {
+			t.Errorf("string of %d bytes has tag 0x%02x, want 0x%02x", tt.size, tag, tt.tag)
+		}
+		if got := msgpackRoundTrip(t, map[string]interface{}{"s": value})["s"]; got != value {
+			t.Errorf("round trip of a string of %d bytes = %d bytes", tt.size, len(got.(string)))
+		}
+	}
+}
+
+func TestMsgpackNested(t *testing.T) {
+	many := make([]interface{}, 20)
+	for i := range many {
+		many[i] = int64(i)
+	}
+	wide := make(map[string]interface{}, 20)
+	for i := 0; i < 20; i++ {
+		wide[strings.Repeat("k", i+1)] = int64(i)
+	}
+	data := map[string]interface{}{
+		"order": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"id": int64(1), "qty": 2.5},
+				map[string]interface{}{"id": int64(2), "tags": []interface{}{"a", nil, true}},
+			},
+			"address": map[string]interface{}{"city": "Tehran", "zip": nil},
+		},
+		"many":  many,
+		"wide":  wide,
+		"flag":  false,
+		"ratio": 0.125,
+		"nil":   nil,
+	}
+	if got := msgpackRoundTrip(t, data); !reflect.DeepEqual(got, data) {
+		t.Errorf("round trip = %#v, want %#v", got, data)
+	}
+}
+
+func TestMsgpackTypedValues(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+	}
+	data := map[string]interface{}{
+		"strings": []string{"a", "b"},
+		"ints":    map[string]int{"x": 1},
+		"struct":  item{Name: "pen"},
+		"pointer": &item{Name: "ink"},
+		"byID":    map[int]string{7: "seven"},
+		"float32": float32(1.5),
+	}
+	want := map[string]interface{}{
+		"strings": []interface{}{"a", "b"},
+		"ints":    map[string]interface{}{"x": int64(1)},
+		"struct":  map[string]interface{}{"name": "pen"},
+		"pointer": map[string]interface{}{"name": "ink"},
+		"byID":    map[string]interface{}{"7": "seven"},
+		"float32": 1.5,
+	}
+	if got := msgpackRoundTrip(t, data); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %#v, want %#v", got, want)
+	}
+}
+
+func TestMsgpackDecodeInvalid(t *testing.T) {
+	inputs := [][]byte{
+		{0x81},             // map missing its entry
+		{0x81, 0xa1, 'a'},  // map missing a value
+		{0xd9, 0x05, 'a'},  // short str8
+		{0xc4},             // bin8 missing its length
+		{0xdd, 0xff, 0xff}, // short array32 length
+		{0xc1},             // never used
+		{0x92, 0x01},       // array missing an item
+		{0xa1, 'a'},        // not a map
+	}
+	for _, raw := range inputs {
+		if data, err := MsgpackCodec.Decode(raw); err == nil {
+			t.Errorf("Decode(% x) = %v, want an error", raw, data)
+		}
+	}
+}
+
+func TestCodecsDecodeEmpty(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec, MsgpackCodec} {
+		for _, raw := range [][]byte{nil, {}} {
+			data, err := codec.Decode(raw)
+			if err != nil || data == nil || len(data) != 0 {
+				t.Errorf("%s Decode(%q) = %v, %v, want an empty map", codec.Name(), raw, data, err)
+			}
+		}
+	}
+	data, err := JSONCodec.Decode([]byte("null"))
+	if err != nil || data == nil {
+		t.Errorf("json Decode(null) = %v, %v, want an empty map", data, err)
+	}
+	data, err = MsgpackCodec.Decode([]byte{0xc0})
+	if err != nil || data == nil {
+		t.Errorf("msgpack Decode(nil) = %v, %v, want an empty map", data, err)
+	}
+}
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultSweepInterval is how often MemoryStorage removes expired users
+const DefaultSweepInterval = 30 * time.Second
+
+// ExpiringStorage is implemented by storages that can remove a user's
+// context (state and data) after a time to live
+type ExpiringStorage interface {
+	BaseStorage
+	// Expire removes the context of key after ttl. A ttl <= 0 cancels a pending expiry.
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	// TTL returns the time left before key expires, 0 if it doesn't expire
+	TTL(ctx context.Context, key string) (time.Duration, error)
+}
+
+// ExpireFunc receives a user context removed after its TTL
+type ExpireFunc func(key string, expired *UserContext)
+
+// ExpiryNotifier is implemented by storages that report expired contexts
+type ExpiryNotifier interface {
+	OnExpire(fn ExpireFunc)
+}
@@ -0,0 +1,471 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrStorageClosed is returned by storages used after Close
+var ErrStorageClosed = errors.New("storage is closed")
+
+// FileStorageOptions configures a FileStorage
+type FileStorageOptions struct {
+	NoSync           bool // Skip fsync after every write (faster, loses the last writes on power failure)
+	CompactThreshold int  // Compact when the log holds this many more records than live keys (default 1000, -1 disables)
+}
+
+// fileRecord is one line of the log: the full context of a key after a write,
+// or its removal
+type fileRecord struct {
+	Key     string                 `json:"k"`
+	State   string                 `json:"s,omitempty"`
+	Data    map[string]interface{} `json:"d,omitempty"`
+	Deleted bool                   `json:"x,omitempty"`
+}
+
+// FileStorage implements BaseStorage with an append-only JSON lines log.
+//
+// Every write appends the full context of the user and is fsynced before
+// returning. On open the log is replayed; a torn last line left by a crash is
+// discarded. The log is compacted into a fresh file (written to a temporary
+// file and renamed over the old one) once it holds enough stale records.
+//
+// Data values are stored as JSON, so numbers read back as float64 and
+// structs as map[string]interface{}, as with any persistent storage.
+type FileStorage struct {
+	mu        sync.RWMutex
+	path      string
+	file      *os.File
+	contexts  map[string]*UserContext
+	records   int
+	noSync    bool
+	threshold int
+	closed    bool
+}
+
+// NewFileStorage opens (or creates) a file storage at path.
+// Pass nil options to use the defaults.
+func NewFileStorage(path string, options *FileStorageOptions) (*FileStorage, error) {
+	if options == nil {
+		options = &FileStorageOptions{}
+	}
+	threshold := options.CompactThreshold
+	if threshold == 0 {
+		threshold = 1000
+	}
+
+	f := &FileStorage{
+		path:      path,
+		contexts:  make(map[string]*UserContext),
+		noSync:    options.NoSync,
+		threshold: threshold,
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage file: %w", err)
+	}
+	if err := f.replay(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	f.file = file
+	return f, nil
+}
+
+// replay loads the log and truncates a torn last record
+func (f *FileStorage) replay(file *os.File) error {
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A last line without newline was cut short by a crash
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read storage file: %w", err)
+		}
+
+		var record fileRecord
+		if jsonErr := json.Unmarshal(bytes.TrimSpace(line), &record); jsonErr != nil {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				// Torn write of the last record
+				break
+			}
+			return fmt.Errorf("corrupt storage file at offset %d: %w", offset, jsonErr)
+		}
+		f.apply(&record)
+		f.records++
+		offset += int64(len(line))
+	}
+
+	if err := file.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate storage file: %w", err)
+	}
+	_, err := file.Seek(offset, io.SeekStart)
+	return err
+}
+
+func (f *FileStorage) apply(record *fileRecord) {
+	if record.Deleted {
+		delete(f.contexts, record.Key)
+		return
+	}
+	data := record.Data
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	f.contexts[record.Key] = &UserContext{State: record.State, Data: data}
+}
+
+// write appends the context of key (or its removal) to the log.
+// The in-memory context is replaced by its JSON round-trip so values read the
+// same before and after a restart. Must be called with the lock held.
+func (f *FileStorage) write(key string, uc *UserContext) error {
+	if f.closed {
+		return ErrStorageClosed
+	}
+
+	record := fileRecord{Key: key, Deleted: uc == nil}
+	if uc != nil {
+		record.State = uc.State
+		record.Data = uc.Data
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode user context: %w", err)
+	}
+	line = append(line, '\n')
+
+	offset, err := f.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to write storage file: %w", err)
+	}
+	if _, err := f.file.Write(line); err != nil {
+		// Drop a partial record so later appends don't follow garbage
+		f.rollback(offset)
+		return fmt.Errorf("failed to write storage file: %w", err)
+	}
+	if !f.noSync {
+		if err := f.file.Sync(); err != nil {
+			// The record may not be durable: drop it, as the caller is told
+			// the write failed
+			f.rollback(offset)
+			return fmt.Errorf("failed to sync storage file: %w", err)
+		}
+	}
+
+	var stored fileRecord
+	if err := json.Unmarshal(line, &stored); err != nil {
+		return fmt.Errorf("failed to decode user context: %w", err)
+	}
+	f.apply(&stored)
+	f.records++
+
+	if f.threshold > 0 && f.records-len(f.contexts) >= f.threshold {
+		// The record is written: a failed compaction leaves the log valid
+		// and is retried on the next write
+		if err := f.compact(); err != nil {
+			log.Printf("File storage compaction error: %v", err)
+		}
+	}
+	return nil
+}
+
+// rollback truncates the log back to offset
+func (f *FileStorage) rollback(offset int64) {
+	f.file.Truncate(offset)
+	f.file.Seek(offset, io.SeekStart)
+}
+
+// Compact rewrites the log with one record per live key
+func (f *FileStorage) Compact() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrStorageClosed
+	}
+	return f.compact()
+}
+
+func (f *FileStorage) compact() error {
+	dir := filepath.Dir(f.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(f.path)+".compact-*")
+	if err != nil {
+		return fmt.Errorf("failed to create compaction file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmpPath)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for key, uc := range f.contexts {
+		if err := encoder.Encode(fileRecord{Key: key, State: uc.State, Data: uc.Data}); err != nil {
+			cleanup()
+			return fmt.Errorf("failed to encode user context: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		cleanup()
+		return fmt.Errorf("failed to write compaction file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		return fmt.Errorf("failed to sync compaction file: %w", err)
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		cleanup()
+		return err
+	}
+	// The temporary file becomes the log; keep appending to it. Seek before
+	// the rename, so a failure leaves the current log in place.
+	if _, err := tmp.Seek(0, io.SeekEnd); err != nil {
+		cleanup()
+		return err
+	}
+	if err := os.Rename(tmpPath, f.path); err != nil {
+		cleanup()
+		return fmt.Errorf("failed to replace storage file: %w", err)
+	}
+	syncDir(dir)
+
+	f.file.Close()
+	f.file = tmp
+	f.records = len(f.contexts)
+	return nil
+}
+
+// syncDir makes a rename durable. Errors are ignored: not every platform
+// supports syncing directories.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// current returns a copy of the context of key, or an empty context
+func (f *FileStorage) current(key string) *UserContext {
+	if uc, ok := f.contexts[key]; ok {
+		return copyContext(uc)
+	}
+	return &UserContext{Data: make(map[string]interface{})}
+}
+
+// GetContext retrieves the complete user context (state + data)
+func (f *FileStorage) GetContext(ctx context.Context, key string) (*UserContext, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return nil, ErrStorageClosed
+	}
+	return f.current(key), nil
+}
+
+// GetState retrieves only the state for a user
+func (f *FileStorage) GetState(ctx context.Context, key string) (string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return "", ErrStorageClosed
+	}
+	if uc, ok := f.contexts[key]; ok {
+		return uc.State, nil
+	}
+	return "", nil
+}
+
+// SetState sets the state for a user
+func (f *FileStorage) SetState(ctx context.Context, key string, state string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	uc := f.current(key)
+	uc.State = state
+	return f.write(key, uc)
+}
+
+// ClearState clears the state for a user
+func (f *FileStorage) ClearState(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrStorageClosed
+	}
+	if _, ok := f.contexts[key]; !ok {
+		return nil
+	}
+	uc := f.current(key)
+	uc.State = ""
+	return f.write(key, uc)
+}
+
+// GetData retrieves only the data for a user
+func (f *FileStorage) GetData(ctx context.Context, key string) (map[string]interface{}, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return nil, ErrStorageClosed
+	}
+	return f.current(key).Data, nil
+}
+
+// UpsertData updates or inserts data for a user
+func (f *FileStorage) UpsertData(ctx context.Context, key string, data map[string]interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	uc := f.current(key)
+	for k, v := range data {
+		uc.Data[k] = v
+	}
+	return f.write(key, uc)
+}
+
+// ClearData clears all data for a user
+func (f *FileStorage) ClearData(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrStorageClosed
+	}
+	if _, ok := f.contexts[key]; !ok {
+		return nil
+	}
+	uc := f.current(key)
+	uc.Data = make(map[string]interface{})
+	return f.write(key, uc)
+}
+
+// DeleteData removes fields from the data of a user
+func (f *FileStorage) DeleteData(ctx context.Context, key string, fields ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrStorageClosed
+	}
+	if _, ok := f.contexts[key]; !ok {
+		return nil
+	}
+	uc := f.current(key)
+	for _, field := range fields {
+		delete(uc.Data, field)
+	}
+	return f.write(key, uc)
+}
+
+// UpsertContext updates or inserts the complete user context
+func (f *FileStorage) UpsertContext(ctx context.Context, key string, state string, data map[string]interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	uc := f.current(key)
+	if state != "" {
+		uc.State = state
+	}
+	for k, v := range data {
+		uc.Data[k] = v
+	}
+	return f.write(key, uc)
+}
+
+// ClearAll removes all data and state for a user
+func (f *FileStorage) ClearAll(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrStorageClosed
+	}
+	if _, ok := f.contexts[key]; !ok {
+		return nil
+	}
+	return f.write(key, nil)
+}
+
+// Update applies fn to the context of key and appends the result.
+// The storage is locked while fn runs, so fn runs exactly once.
+func (f *FileStorage) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrStorageClosed
+	}
+	uc := f.current(key)
+	if err := fn(uc); err != nil {
+		return err
+	}
+	if uc.Data == nil {
+		uc.Data = make(map[string]interface{})
+	}
+	return f.write(key, uc)
+}
+
+// Close syncs and closes the log file
+func (f *FileStorage) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+
+	syncErr := f.file.Sync()
+	closeErr := f.file.Close()
+	if syncErr != nil {
+		return syncErr
+	}
+	return closeErr
+}
+
+// Count returns the number of users in storage
+func (f *FileStorage) Count() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return len(f.contexts)
+}
+
+// Scan returns a page of keys in key order (see IterableStorage)
+func (f *FileStorage) Scan(ctx context.Context, cursor string, match string, count int) ([]string, string, error) {
+	if err := validateMatch(match); err != nil {
+		return nil, "", err
+	}
+
+	f.mu.RLock()
+	if f.closed {
+		f.mu.RUnlock()
+		return nil, "", ErrStorageClosed
+	}
+	keys := make([]string, 0, len(f.contexts))
+	for key := range f.contexts {
+		keys = append(keys, key)
+	}
+	f.mu.RUnlock()
+
+	page, next := scanKeys(keys, cursor, match, count)
+	return page, next, nil
+}
+
+// CountByState returns the number of keys in each state
+func (f *FileStorage) CountByState(ctx context.Context) (map[string]int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.closed {
+		return nil, ErrStorageClosed
+	}
+
+	counts := make(map[string]int)
+	for _, uc := range f.contexts {
+		counts[uc.State]++
+	}
+	return counts, nil
+}
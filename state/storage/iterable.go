@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"path"
+	"sort"
+)
+
+// DefaultScanCount is the page size of Scan when count <= 0
+const DefaultScanCount = 100
+
+// IterableStorage is implemented by storages that can enumerate their keys
+type IterableStorage interface {
+	BaseStorage
+	// Scan returns a page of keys matching match (path.Match syntax, "" for
+	// all) starting at cursor ("" for the first page), and the cursor of the
+	// next page, "" once all keys were returned. count is a hint for the page
+	// size; a page may hold fewer keys, even none, before the last one.
+	Scan(ctx context.Context, cursor string, match string, count int) (keys []string, next string, err error)
+	// CountByState returns the number of keys in each state; keys without
+	// a state are counted under ""
+	CountByState(ctx context.Context) (map[string]int, error)
+}
+
+// validateMatch reports a malformed Scan pattern
+func validateMatch(match string) error {
+	if match == "" {
+		return nil
+	}
+	_, err := path.Match(match, "")
+	return err
+}
+
+// matchKey reports whether key matches a validated Scan pattern
+func matchKey(match, key string) bool {
+	if match == "" {
+		return true
+	}
+	ok, _ := path.Match(match, key)
+	return ok
+}
+
+// scanKeys pages through the keys of an in-process storage in key order.
+// keys may be in any order; they are sorted in place.
+func scanKeys(keys []string, cursor string, match string, count int) ([]string, string) {
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	sort.Strings(keys)
+	start := sort.SearchStrings(keys, cursor)
+	if cursor != "" && start < len(keys) && keys[start] == cursor {
+		start++
+	}
+
+	page := make([]string, 0, count)
+	for i := start; i < len(keys); i++ {
+		if !matchKey(match, keys[i]) {
+			continue
+		}
+		if len(page) == count {
+			return page, page[len(page)-1]
+		}
+		page = append(page, keys[i])
+	}
+	return page, ""
+}
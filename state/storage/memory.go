@@ -3,18 +3,26 @@ package storage
 import (
 	"context"
 	"sync"
+	"time"
 )
 
 // MemoryStorage implements BaseStorage using in-memory storage
 type MemoryStorage struct {
-	mu      sync.RWMutex
-	storage map[string]*UserContext
+	mu        sync.RWMutex
+	storage   map[string]*UserContext
+	expires   map[string]time.Time
+	onExpire  []ExpireFunc
+	stopSweep chan struct{}
+	versions  map[string]uint64 // Bumped on every write, for Update
+	clock     uint64
 }
 
 // NewMemoryStorage creates a new memory storage instance
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
-		storage: make(map[string]*UserContext),
+		storage:  make(map[string]*UserContext),
+		expires:  make(map[string]time.Time),
+		versions: make(map[string]uint64),
 	}
 }
 
@@ -23,7 +31,7 @@ func (m *MemoryStorage) GetContext(ctx context.Context, key string) (*UserContex
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if uc, ok := m.storage[key]; ok {
+	if uc, ok := m.live(key); ok {
 		// Return a copy to prevent external modifications
 		data := make(map[string]interface{})
 		for k, v := range uc.Data {
@@ -47,7 +55,7 @@ func (m *MemoryStorage) GetState(ctx context.Context, key string) (string, error
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if uc, ok := m.storage[key]; ok {
+	if uc, ok := m.live(key); ok {
 		return uc.State, nil
 	}
 	return "", nil
@@ -57,6 +65,8 @@ func (m *MemoryStorage) GetState(ctx context.Context, key string) (string, error
 func (m *MemoryStorage) SetState(ctx context.Context, key string, state string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.reap(key)
+	defer m.bump(key)
 
 	if uc, ok := m.storage[key]; ok {
 		uc.State = state
@@ -73,6 +83,8 @@ func (m *MemoryStorage) SetState(ctx context.Context, key string, state string)
 func (m *MemoryStorage) ClearState(ctx context.Context, key string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.reap(key)
+	defer m.bump(key)
 
 	if uc, ok := m.storage[key]; ok {
 		uc.State = ""
@@ -85,7 +97,7 @@ func (m *MemoryStorage) GetData(ctx context.Context, key string) (map[string]int
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if uc, ok := m.storage[key]; ok {
+	if uc, ok := m.live(key); ok {
 		// Return a copy to prevent external modifications
 		data := make(map[string]interface{})
 		for k, v := range uc.Data {
@@ -100,6 +112,8 @@ func (m *MemoryStorage) GetData(ctx context.Context, key string) (map[string]int
 func (m *MemoryStorage) UpsertData(ctx context.Context, key string, data map[string]interface{}) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.reap(key)
+	defer m.bump(key)
 
 	if uc, ok := m.storage[key]; ok {
 		// Merge new data with existing data
@@ -124,6 +138,8 @@ func (m *MemoryStorage) UpsertData(ctx context.Context, key string, data map[str
 func (m *MemoryStorage) ClearData(ctx context.Context, key string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.reap(key)
+	defer m.bump(key)
 
 	if uc, ok := m.storage[key]; ok {
 		uc.Data = make(map[string]interface{})
@@ -131,10 +147,27 @@ func (m *MemoryStorage) ClearData(ctx context.Context, key string) error {
 	return nil
 }
 
+// DeleteData removes fields from the data of a user
+func (m *MemoryStorage) DeleteData(ctx context.Context, key string, fields ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reap(key)
+	defer m.bump(key)
+
+	if uc, ok := m.storage[key]; ok {
+		for _, field := range fields {
+			delete(uc.Data, field)
+		}
+	}
+	return nil
+}
+
 // UpsertContext updates or inserts the complete user context
 func (m *MemoryStorage) UpsertContext(ctx context.Context, key string, state string, data map[string]interface{}) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.reap(key)
+	defer m.bump(key)
 
 	if uc, ok := m.storage[key]; ok {
 		// Update existing context
@@ -164,15 +197,23 @@ func (m *MemoryStorage) ClearAll(ctx context.Context, key string) error {
 	defer m.mu.Unlock()
 
 	delete(m.storage, key)
+	delete(m.expires, key)
+	delete(m.versions, key)
 	return nil
 }
 
-// Close closes the storage (no-op for memory storage)
+// Close closes the storage, clearing all users and stopping the sweeper
 func (m *MemoryStorage) Close() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if m.stopSweep != nil {
+		close(m.stopSweep)
+		m.stopSweep = nil
+	}
 	m.storage = make(map[string]*UserContext)
+	m.expires = make(map[string]time.Time)
+	m.versions = make(map[string]uint64)
 	return nil
 }
 
@@ -183,3 +224,224 @@ func (m *MemoryStorage) Count() int {
 
 	return len(m.storage)
 }
+
+// live returns the context of key unless it has expired. Must be called with the lock held.
+func (m *MemoryStorage) live(key string) (*UserContext, bool) {
+	uc, ok := m.storage[key]
+	if !ok {
+		return nil, false
+	}
+	if expiresAt, ok := m.expires[key]; ok && !time.Now().Before(expiresAt) {
+		return nil, false
+	}
+	return uc, true
+}
+
+// reap removes key if it has expired, before a write. Must be called with the write lock held.
+func (m *MemoryStorage) reap(key string) {
+	expiresAt, ok := m.expires[key]
+	if !ok || time.Now().Before(expiresAt) {
+		return
+	}
+	uc := m.storage[key]
+	delete(m.storage, key)
+	delete(m.expires, key)
+	delete(m.versions, key)
+	if uc != nil && len(m.onExpire) > 0 {
+		// The caller holds the lock; callbacks may use the storage
+		go m.notify(key, uc, m.onExpire)
+	}
+}
+
+// Expire removes the context of key after ttl. A ttl <= 0 cancels a pending expiry.
+// The sweeper is started with DefaultSweepInterval if it isn't running.
+func (m *MemoryStorage) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ttl <= 0 {
+		delete(m.expires, key)
+		return nil
+	}
+	if _, ok := m.storage[key]; !ok {
+		return nil
+	}
+	m.expires[key] = time.Now().Add(ttl)
+	if m.stopSweep == nil {
+		m.startSweeper(DefaultSweepInterval)
+	}
+	return nil
+}
+
+// TTL returns the time left before key expires, 0 if it doesn't expire
+func (m *MemoryStorage) TTL(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	expiresAt, ok := m.expires[key]
+	if !ok {
+		return 0, nil
+	}
+	if left := time.Until(expiresAt); left > 0 {
+		return left, nil
+	}
+	return 0, nil
+}
+
+// OnExpire adds a callback receiving contexts removed after their TTL.
+// Callbacks run on the sweeper goroutine.
+func (m *MemoryStorage) OnExpire(fn ExpireFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.onExpire = append(m.onExpire, fn)
+}
+
+// StartSweeper removes expired users every interval until Close.
+// Restarts the sweeper if it is already running.
+func (m *MemoryStorage) StartSweeper(interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopSweep != nil {
+		close(m.stopSweep)
+	}
+	m.startSweeper(interval)
+}
+
+func (m *MemoryStorage) startSweeper(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+	stop := make(chan struct{})
+	m.stopSweep = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.Sweep()
+			}
+		}
+	}()
+}
+
+// Sweep removes expired users now, runs the expiry callbacks and returns
+// the number of users removed
+func (m *MemoryStorage) Sweep() int {
+	now := time.Now()
+	expired := make(map[string]*UserContext)
+
+	m.mu.Lock()
+	for key, expiresAt := range m.expires {
+		if now.Before(expiresAt) {
+			continue
+		}
+		if uc, ok := m.storage[key]; ok {
+			expired[key] = uc
+		}
+		delete(m.storage, key)
+		delete(m.expires, key)
+		delete(m.versions, key)
+	}
+	callbacks := m.onExpire
+	m.mu.Unlock()
+
+	for key, uc := range expired {
+		m.notify(key, uc, callbacks)
+	}
+	return len(expired)
+}
+
+func (m *MemoryStorage) notify(key string, uc *UserContext, callbacks []ExpireFunc) {
+	for _, fn := range callbacks {
+		fn(key, uc)
+	}
+}
+
+// bump gives key a new version after a write. Must be called with the write lock held.
+func (m *MemoryStorage) bump(key string) {
+	if _, ok := m.storage[key]; !ok {
+		delete(m.versions, key)
+		return
+	}
+	m.clock++
+	m.versions[key] = m.clock
+}
+
+// Update applies fn to a copy of the context of key and stores the result
+// if no other write happened meanwhile (compare-and-swap on a per-key
+// version), retrying otherwise. fn runs without holding the storage lock.
+func (m *MemoryStorage) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	for attempt := 0; attempt < maxUpdateRetries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		m.mu.RLock()
+		uc, exists := m.live(key)
+		version := m.versions[key]
+		working := &UserContext{Data: make(map[string]interface{})}
+		if exists {
+			working = copyContext(uc)
+		}
+		m.mu.RUnlock()
+
+		if err := fn(working); err != nil {
+			return err
+		}
+		if working.Data == nil {
+			working.Data = make(map[string]interface{})
+		}
+
+		m.mu.Lock()
+		_, stillExists := m.live(key)
+		if stillExists != exists || m.versions[key] != version {
+			m.mu.Unlock()
+			continue
+		}
+		m.reap(key)
+		m.storage[key] = copyContext(working)
+		m.bump(key)
+		m.mu.Unlock()
+		return nil
+	}
+	return ErrUpdateConflict
+}
+
+// Scan returns a page of live keys in key order (see IterableStorage)
+func (m *MemoryStorage) Scan(ctx context.Context, cursor string, match string, count int) ([]string, string, error) {
+	if err := validateMatch(match); err != nil {
+		return nil, "", err
+	}
+
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.storage))
+	for key := range m.storage {
+		if _, ok := m.live(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	m.mu.RUnlock()
+
+	page, next := scanKeys(keys, cursor, match, count)
+	return page, next, nil
+}
+
+// CountByState returns the number of live keys in each state
+func (m *MemoryStorage) CountByState(ctx context.Context) (map[string]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for key := range m.storage {
+		if uc, ok := m.live(key); ok {
+			counts[uc.State]++
+		}
+	}
+	return counts, nil
+}
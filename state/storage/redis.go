@@ -0,0 +1,475 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	redisStateField = "state"
+	redisDataField  = "data"
+	redisTTLField   = "ttl" // Per-key TTL set with Expire, in milliseconds
+
+	// redisMaxRetries bounds optimistic transaction retries on concurrent writes
+	redisMaxRetries = 16
+)
+
+// errRedisConflict signals an aborted transaction, to be retried
+var errRedisConflict = errors.New("redis: concurrent update, transaction aborted")
+
+// RedisStorageOptions configures a RedisStorage
+type RedisStorageOptions struct {
+	Prefix    string        // Key prefix (default "egobot")
+	Namespace string        // Optional namespace, e.g. one per bot sharing a server
+	TTL       time.Duration // Expire idle users after TTL, refreshed on every write (0 keeps them)
+	Codec     Codec         // Serialization of data (default JSONCodec)
+}
+
+// RedisStorage implements BaseStorage on Redis, so several bot instances
+// can share state.
+//
+// Every user is a hash at "<prefix>:<namespace>:<key>" with a "state" field
+// and a "data" field holding the data encoded with the codec. Data updates
+// are merged in WATCH/MULTI/EXEC transactions when the connection
+// implements RedisSessionConn (RedisClient does).
+//
+// The TTL option is refreshed on every write. A per-key TTL set with Expire
+// replaces it until the expiry is cancelled or the state is set again.
+//
+// Example:
+//
+//	client := storage.NewRedisClient(storage.RedisOptions{Addr: "localhost:6379"})
+//	store := storage.NewRedisStorage(client, &storage.RedisStorageOptions{
+//		Namespace: "shop_bot",
+//		TTL:       24 * time.Hour,
+//	})
+//	bot.SetStorage(store)
+type RedisStorage struct {
+	conn      RedisConn
+	prefix    string
+	namespace string
+	ttl       time.Duration
+	codec     Codec
+}
+
+// NewRedisStorage creates a Redis storage on conn.
+// Pass nil options to use the defaults.
+func NewRedisStorage(conn RedisConn, options *RedisStorageOptions) *RedisStorage {
+	if options == nil {
+		options = &RedisStorageOptions{}
+	}
+	r := &RedisStorage{
+		conn:      conn,
+		prefix:    options.Prefix,
+		namespace: options.Namespace,
+		ttl:       options.TTL,
+		codec:     options.Codec,
+	}
+	if r.prefix == "" {
+		r.prefix = "egobot"
+	}
+	if r.codec == nil {
+		r.codec = JSONCodec
+	}
+	return r
+}
+
+// Key returns the Redis key holding a user's context
+func (r *RedisStorage) Key(key string) string {
+	parts := []string{r.prefix}
+	if r.namespace != "" {
+		parts = append(parts, r.namespace)
+	}
+	return strings.Join(append(parts, key), ":")
+}
+
+// Codec returns the codec used for data
+func (r *RedisStorage) Codec() Codec {
+	return r.codec
+}
+
+func (r *RedisStorage) decodeData(reply interface{}) (map[string]interface{}, error) {
+	raw, _ := reply.(string)
+	data, err := r.codec.Decode([]byte(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode user data (%s): %w", r.codec.Name(), err)
+	}
+	return data, nil
+}
+
+// GetContext retrieves the complete user context (state + data)
+func (r *RedisStorage) GetContext(ctx context.Context, key string) (*UserContext, error) {
+	reply, err := r.conn.Do(ctx, "HMGET", r.Key(key), redisStateField, redisDataField)
+	if err != nil {
+		return nil, err
+	}
+	fields, _ := reply.([]interface{})
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("redis: unexpected HMGET reply %v", reply)
+	}
+	data, err := r.decodeData(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	state, _ := fields[0].(string)
+	return &UserContext{State: state, Data: data}, nil
+}
+
+// GetState retrieves only the state for a user
+func (r *RedisStorage) GetState(ctx context.Context, key string) (string, error) {
+	reply, err := r.conn.Do(ctx, "HGET", r.Key(key), redisStateField)
+	if err != nil {
+		return "", err
+	}
+	state, _ := reply.(string)
+	return state, nil
+}
+
+// SetState sets the state for a user
+func (r *RedisStorage) SetState(ctx context.Context, key string, state string) error {
+	redisKey := r.Key(key)
+	if _, err := r.conn.Do(ctx, "HSET", redisKey, redisStateField, state); err != nil {
+		return err
+	}
+	return r.expire(ctx, r.conn, redisKey)
+}
+
+// ClearState clears the state for a user
+func (r *RedisStorage) ClearState(ctx context.Context, key string) error {
+	_, err := r.conn.Do(ctx, "HDEL", r.Key(key), redisStateField)
+	return err
+}
+
+// GetData retrieves only the data for a user
+func (r *RedisStorage) GetData(ctx context.Context, key string) (map[string]interface{}, error) {
+	reply, err := r.conn.Do(ctx, "HGET", r.Key(key), redisDataField)
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeData(reply)
+}
+
+// UpsertData updates or inserts data for a user
+func (r *RedisStorage) UpsertData(ctx context.Context, key string, data map[string]interface{}) error {
+	return r.merge(ctx, key, "", data)
+}
+
+// ClearData clears all data for a user
+func (r *RedisStorage) ClearData(ctx context.Context, key string) error {
+	_, err := r.conn.Do(ctx, "HDEL", r.Key(key), redisDataField)
+	return err
+}
+
+// DeleteData removes fields from the data of a user
+func (r *RedisStorage) DeleteData(ctx context.Context, key string, fields ...string) error {
+	// Don't create an empty context for a missing key
+	uc, err := r.GetContext(ctx, key)
+	if err != nil || (uc.State == "" && len(uc.Data) == 0) {
+		return err
+	}
+	return r.Update(ctx, key, func(uc *UserContext) error {
+		for _, field := range fields {
+			delete(uc.Data, field)
+		}
+		return nil
+	})
+}
+
+// UpsertContext updates or inserts the complete user context
+func (r *RedisStorage) UpsertContext(ctx context.Context, key string, state string, data map[string]interface{}) error {
+	return r.merge(ctx, key, state, data)
+}
+
+// ClearAll removes all data and state for a user
+func (r *RedisStorage) ClearAll(ctx context.Context, key string) error {
+	_, err := r.conn.Do(ctx, "DEL", r.Key(key))
+	return err
+}
+
+// Close closes the connection if it implements io.Closer
+func (r *RedisStorage) Close() error {
+	if closer, ok := r.conn.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Expire removes the context of key after ttl. A ttl <= 0 cancels the
+// per-key TTL, falling back to the TTL option.
+func (r *RedisStorage) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	redisKey := r.Key(key)
+	if ttl <= 0 {
+		if _, err := r.conn.Do(ctx, "HDEL", redisKey, redisTTLField); err != nil {
+			return err
+		}
+		if r.ttl > 0 {
+			return r.expire(ctx, r.conn, redisKey)
+		}
+		_, err := r.conn.Do(ctx, "PERSIST", redisKey)
+		return err
+	}
+
+	exists, err := r.conn.Do(ctx, "EXISTS", redisKey)
+	if err != nil {
+		return err
+	}
+	if n, _ := exists.(int64); n == 0 {
+		return nil
+	}
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+	if _, err := r.conn.Do(ctx, "HSET", redisKey, redisTTLField, ms); err != nil {
+		return err
+	}
+	_, err = r.conn.Do(ctx, "PEXPIRE", redisKey, ms)
+	return err
+}
+
+// TTL returns the time left before key expires, 0 if it doesn't expire
+func (r *RedisStorage) TTL(ctx context.Context, key string) (time.Duration, error) {
+	reply, err := r.conn.Do(ctx, "PTTL", r.Key(key))
+	if err != nil {
+		return 0, err
+	}
+	ms, _ := reply.(int64)
+	if ms <= 0 {
+		return 0, nil
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func (r *RedisStorage) expire(ctx context.Context, conn RedisConn, redisKey string) error {
+	if r.ttl <= 0 {
+		return nil
+	}
+	_, err := conn.Do(ctx, r.expireArgs(redisKey)...)
+	return err
+}
+
+func (r *RedisStorage) expireArgs(redisKey string) []string {
+	return []string{"PEXPIRE", redisKey, strconv.FormatInt(r.ttl.Milliseconds(), 10)}
+}
+
+// merge sets state (if not empty) and merges data into the stored data
+func (r *RedisStorage) merge(ctx context.Context, key, state string, data map[string]interface{}) error {
+	return r.Update(ctx, key, func(uc *UserContext) error {
+		if state != "" {
+			uc.State = state
+		}
+		for k, v := range data {
+			uc.Data[k] = v
+		}
+		return nil
+	})
+}
+
+// Update applies fn to the context of key in a WATCH/MULTI/EXEC
+// transaction, retrying when the key changes concurrently. Without a
+// RedisSessionConn the read and the write are not atomic.
+func (r *RedisStorage) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	redisKey := r.Key(key)
+	session, ok := r.conn.(RedisSessionConn)
+	if !ok {
+		return r.updateOn(ctx, r.conn, redisKey, fn, false)
+	}
+
+	for attempt := 0; attempt < redisMaxRetries; attempt++ {
+		err := session.Session(ctx, func(conn RedisConn) error {
+			return r.updateOn(ctx, conn, redisKey, fn, true)
+		})
+		if !errors.Is(err, errRedisConflict) {
+			return err
+		}
+
+		// Back off with jitter so concurrent writers don't collide again
+		backoff := time.Millisecond << attempt
+		if backoff > 100*time.Millisecond {
+			backoff = 100 * time.Millisecond
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(rand.Int63n(int64(backoff)) + 1)):
+		}
+	}
+	return ErrUpdateConflict
+}
+
+func (r *RedisStorage) updateOn(ctx context.Context, conn RedisConn, redisKey string, fn UpdateFunc, transaction bool) (err error) {
+	if transaction {
+		if _, err := conn.Do(ctx, "WATCH", redisKey); err != nil {
+			return err
+		}
+		defer func() {
+			if err != nil {
+				conn.Do(ctx, "UNWATCH")
+			}
+		}()
+	}
+
+	reply, err := conn.Do(ctx, "HMGET", redisKey, redisStateField, redisDataField, redisTTLField)
+	if err != nil {
+		return err
+	}
+	fields, _ := reply.([]interface{})
+	if len(fields) != 3 {
+		return fmt.Errorf("redis: unexpected HMGET reply %v", reply)
+	}
+	data, err := r.decodeData(fields[1])
+	if err != nil {
+		return err
+	}
+	state, _ := fields[0].(string)
+	uc := &UserContext{State: state, Data: data}
+	if err := fn(uc); err != nil {
+		return err
+	}
+	if uc.Data == nil {
+		uc.Data = make(map[string]interface{})
+	}
+	raw, err := r.codec.Encode(uc.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode user data (%s): %w", r.codec.Name(), err)
+	}
+
+	set := []string{"HSET", redisKey, redisDataField, string(raw)}
+	if uc.State != "" {
+		set = append(set, redisStateField, uc.State)
+	}
+	commands := [][]string{set}
+	if uc.State == "" && fields[0] != nil {
+		commands = append(commands, []string{"HDEL", redisKey, redisStateField})
+	}
+	// A per-key TTL counts from when it was set and is not refreshed
+	if r.ttl > 0 && fields[2] == nil {
+		commands = append(commands, r.expireArgs(redisKey))
+	}
+
+	if !transaction {
+		for _, args := range commands {
+			if _, err := conn.Do(ctx, args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, err := conn.Do(ctx, "MULTI"); err != nil {
+		return err
+	}
+	for _, args := range commands {
+		if _, err := conn.Do(ctx, args...); err != nil {
+			conn.Do(ctx, "DISCARD")
+			return err
+		}
+	}
+	exec, err := conn.Do(ctx, "EXEC")
+	if err != nil {
+		return err
+	}
+	if exec == nil {
+		return errRedisConflict
+	}
+	results, _ := exec.([]interface{})
+	for _, result := range results {
+		if resultErr, ok := result.(error); ok {
+			return resultErr
+		}
+	}
+	return nil
+}
+
+// keyPrefix returns the prefix of the Redis keys of this storage
+func (r *RedisStorage) keyPrefix() string {
+	return r.Key("")
+}
+
+// Scan pages through the keys with SCAN ... TYPE hash, Redis 6 or later
+// (see IterableStorage). The cursor is Redis's, and pages may hold any
+// number of keys. Without a namespace, keys of namespaced storages under
+// the same prefix are returned too.
+func (r *RedisStorage) Scan(ctx context.Context, cursor string, match string, count int) ([]string, string, error) {
+	if err := validateMatch(match); err != nil {
+		return nil, "", err
+	}
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+	if cursor == "" {
+		cursor = "0"
+	}
+
+	prefix := r.keyPrefix()
+	pattern := match
+	if pattern == "" {
+		pattern = "*"
+	}
+	reply, err := r.conn.Do(ctx, "SCAN", cursor,
+		"MATCH", redisGlobEscaper.Replace(prefix)+pattern,
+		"COUNT", strconv.Itoa(count),
+		"TYPE", "hash")
+	if err != nil {
+		return nil, "", err
+	}
+	parts, _ := reply.([]interface{})
+	if len(parts) != 2 {
+		return nil, "", fmt.Errorf("redis: unexpected SCAN reply %v", reply)
+	}
+	next, _ := parts[0].(string)
+	if next == "0" {
+		next = ""
+	}
+
+	items, _ := parts[1].([]interface{})
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		redisKey, _ := item.(string)
+		key := strings.TrimPrefix(redisKey, prefix)
+		// Redis globs let * match "/", path.Match doesn't
+		if matchKey(match, key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, next, nil
+}
+
+// CountByState returns the number of keys in each state.
+// Every key is scanned and read, so this is O(keys).
+func (r *RedisStorage) CountByState(ctx context.Context) (map[string]int, error) {
+	counts := make(map[string]int)
+	cursor := ""
+	for {
+		keys, next, err := r.Scan(ctx, cursor, "", DefaultScanCount)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			reply, err := r.conn.Do(ctx, "HMGET", r.Key(key), redisStateField, redisDataField)
+			if err != nil {
+				return nil, err
+			}
+			fields, _ := reply.([]interface{})
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("redis: unexpected HMGET reply %v", reply)
+			}
+			if fields[0] == nil && fields[1] == nil {
+				// Removed since it was scanned
+				continue
+			}
+			state, _ := fields[0].(string)
+			counts[state]++
+		}
+		if next == "" {
+			return counts, nil
+		}
+		cursor = next
+	}
+}
+
+// redisGlobEscaper escapes the glob characters of a Redis MATCH pattern
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
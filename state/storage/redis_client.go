@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisError is an error reply of the server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return string(e)
+}
+
+// RedisConn runs Redis commands. Replies are decoded as:
+// simple and bulk strings -> string, integers -> int64, arrays ->
+// []interface{}, null -> nil, error replies -> a RedisError error.
+//
+// RedisClient implements it over TCP; tests can use an in-process stand-in.
+type RedisConn interface {
+	Do(ctx context.Context, args ...string) (interface{}, error)
+}
+
+// RedisSessionConn is implemented by connections that can hand out a single
+// connection for WATCH/MULTI/EXEC transactions. Without it RedisStorage
+// merges data with a plain read-modify-write.
+type RedisSessionConn interface {
+	RedisConn
+	Session(ctx context.Context, fn func(conn RedisConn) error) error
+}
+
+// RedisOptions configures a RedisClient
+type RedisOptions struct {
+	Addr        string // host:port (default "localhost:6379")
+	Username    string
+	Password    string
+	DB          int
+	PoolSize    int           // Idle connections kept (default 10)
+	DialTimeout time.Duration // Default 5s
+}
+
+// RedisClient is a minimal RESP client with a connection pool
+type RedisClient struct {
+	options RedisOptions
+	idle    chan *redisConn
+	mu      sync.Mutex
+	closed  bool
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// NewRedisClient creates a client. Connections are opened on demand.
+func NewRedisClient(options RedisOptions) *RedisClient {
+	if options.Addr == "" {
+		options.Addr = "localhost:6379"
+	}
+	if options.PoolSize <= 0 {
+		options.PoolSize = 10
+	}
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = 5 * time.Second
+	}
+	return &RedisClient{
+		options: options,
+		idle:    make(chan *redisConn, options.PoolSize),
+	}
+}
+
+// Do runs a command on a pooled connection
+func (c *RedisClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	var reply interface{}
+	err := c.Session(ctx, func(conn RedisConn) error {
+		var err error
+		reply, err = conn.Do(ctx, args...)
+		return err
+	})
+	return reply, err
+}
+
+// Session runs fn with one connection, so WATCH and MULTI apply to the
+// commands fn sends
+func (c *RedisClient) Session(ctx context.Context, fn func(conn RedisConn) error) error {
+	conn, err := c.get(ctx)
+	if err != nil {
+		return err
+	}
+	session := &redisSession{conn: conn}
+	err = fn(session)
+	if session.broken {
+		conn.conn.Close()
+	} else {
+		c.put(conn)
+	}
+	return err
+}
+
+// Ping checks the connection to the server
+func (c *RedisClient) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// Close closes all idle connections. Connections in use are closed when returned.
+func (c *RedisClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.idle)
+	for conn := range c.idle {
+		conn.conn.Close()
+	}
+	return nil
+}
+
+func (c *RedisClient) get(ctx context.Context) (*redisConn, error) {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil, ErrStorageClosed
+	}
+
+	select {
+	case conn, ok := <-c.idle:
+		if ok {
+			return conn, nil
+		}
+		return nil, ErrStorageClosed
+	default:
+	}
+	return c.dial(ctx)
+}
+
+func (c *RedisClient) put(conn *redisConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		conn.conn.Close()
+		return
+	}
+	select {
+	case c.idle <- conn:
+	default:
+		conn.conn.Close()
+	}
+}
+
+func (c *RedisClient) dial(ctx context.Context) (*redisConn, error) {
+	dialer := net.Dialer{Timeout: c.options.DialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.options.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	conn := &redisConn{
+		conn:   netConn,
+		reader: bufio.NewReader(netConn),
+		writer: bufio.NewWriter(netConn),
+	}
+
+	var setup [][]string
+	if c.options.Password != "" {
+		if c.options.Username != "" {
+			setup = append(setup, []string{"AUTH", c.options.Username, c.options.Password})
+		} else {
+			setup = append(setup, []string{"AUTH", c.options.Password})
+		}
+	}
+	if c.options.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.options.DB)})
+	}
+	for _, args := range setup {
+		if _, err := conn.do(ctx, args); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// redisSession wraps a pooled connection and remembers whether it broke
+type redisSession struct {
+	conn   *redisConn
+	broken bool
+}
+
+func (s *redisSession) Do(ctx context.Context, args ...string) (interface{}, error) {
+	if s.broken {
+		return nil, errors.New("redis: connection is broken")
+	}
+	reply, err := s.conn.do(ctx, args)
+	var replyErr RedisError
+	if err != nil && !errors.As(err, &replyErr) {
+		s.broken = true
+	}
+	return reply, err
+}
+
+func (c *redisConn) do(ctx context.Context, args []string) (interface{}, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.conn.SetDeadline(deadline)
+	} else {
+		c.conn.SetDeadline(time.Time{})
+	}
+
+	fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return readRESP(c.reader)
+}
+
+// readRESP reads one reply of the RESP2 protocol
+func readRESP(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, RedisError(body)
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid integer %q", body)
+		}
+		return n, nil
+	case '$':
+		size, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", body)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return string(buf[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length %q", body)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			item, err := readRESP(reader)
+			var replyErr RedisError
+			if err != nil && !errors.As(err, &replyErr) {
+				return nil, err
+			}
+			if err != nil {
+				item = err
+			}
+			items[i] = item
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
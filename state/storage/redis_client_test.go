@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRESP(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  interface{}
+	}{
+		{"simple string", "+OK\r\n", "OK"},
+		{"integer", ":-42\r\n", int64(-42)},
+		{"bulk string", "$5\r\nhello\r\n", "hello"},
+		{"bulk string with CRLF", "$4\r\na\r\nb\r\n", "a\r\nb"},
+		{"empty bulk string", "$0\r\n\r\n", ""},
+		{"null bulk string", "$-1\r\n", nil},
+		{"null array", "*-1\r\n", nil},
+		{"empty array", "*0\r\n", []interface{}{}},
+		{
+			"array with null items",
+			"*3\r\n$5\r\nstate\r\n$-1\r\n:7\r\n",
+			[]interface{}{"state", nil, int64(7)},
+		},
+		{
+			"nested arrays",
+			"*2\r\n$1\r\n0\r\n*2\r\n$5\r\nego:1\r\n*1\r\n+deep\r\n",
+			[]interface{}{"0", []interface{}{"ego:1", []interface{}{"deep"}}},
+		},
+		{
+			"error items",
+			"*3\r\n+OK\r\n-ERR wrong type\r\n:1\r\n",
+			[]interface{}{"OK", RedisError("ERR wrong type"), int64(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRESP(bufio.NewReader(strings.NewReader(tt.input)))
+			if err != nil {
+				t.Fatalf("readRESP(%q) error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readRESP(%q) = %#v, want %#v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRESPErrorReply(t *testing.T) {
+	_, err := readRESP(bufio.NewReader(strings.NewReader("-WRONGTYPE Operation against a key\r\n")))
+	var replyErr RedisError
+	if !errors.As(err, &replyErr) {
+		t.Fatalf("error = %v, want a RedisError", err)
+	}
+	if replyErr != "WRONGTYPE Operation against a key" {
+		t.Errorf("error = %q", replyErr)
+	}
+}
+
+func TestReadRESPSequence(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("$3\r\nfoo\r\n:1\r\n"))
+	first, err := readRESP(reader)
+	if err != nil || first != "foo" {
+		t.Fatalf("first reply = %v, %v", first, err)
+	}
+	second, err := readRESP(reader)
+	if err != nil || second != int64(1) {
+		t.Fatalf("second reply = %v, %v", second, err)
+	}
+}
+
+func TestReadRESPInvalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"+OK\n",
+		"?1\r\n",
+		":abc\r\n",
+		"$x\r\n",
+		"$5\r\nab\r\n",
+		"*2\r\n+OK\r\n",
+		"*x\r\n",
+	}
+	for _, input := range inputs {
+		_, err := readRESP(bufio.NewReader(strings.NewReader(input)))
+		if err == nil {
+			t.Errorf("readRESP(%q) succeeded, want an error", input)
+			continue
+		}
+		var replyErr RedisError
+		if errors.As(err, &replyErr) {
+			t.Errorf("readRESP(%q) = RedisError %v, want a protocol error", input, err)
+		}
+	}
+}
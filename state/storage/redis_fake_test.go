@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeRedis is an in-process RedisConn holding hashes, with the commands
+// RedisStorage uses. Time is controlled by advance.
+type fakeRedis struct {
+	mu       sync.Mutex
+	now      time.Time
+	hashes   map[string]map[string]string
+	expires  map[string]time.Time
+	versions map[string]int
+	commands [][]string
+
+	// beforeExec runs once before the next EXEC, e.g. to write concurrently
+	beforeExec func()
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{
+		now:      time.Unix(1700000000, 0),
+		hashes:   make(map[string]map[string]string),
+		expires:  make(map[string]time.Time),
+		versions: make(map[string]int),
+	}
+}
+
+// advance moves the clock, expiring keys
+func (f *fakeRedis) advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// keys returns the live keys, sorted
+func (f *fakeRedis) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for key := range f.hashes {
+		if f.alive(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeRedis) Do(ctx context.Context, args ...string) (interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.do(args)
+}
+
+func (f *fakeRedis) Session(ctx context.Context, fn func(conn RedisConn) error) error {
+	return fn(&fakeRedisSession{redis: f})
+}
+
+// alive drops key if it expired
+func (f *fakeRedis) alive(key string) bool {
+	if deadline, ok := f.expires[key]; ok && !f.now.Before(deadline) {
+		f.delete(key)
+	}
+	_, ok := f.hashes[key]
+	return ok
+}
+
+func (f *fakeRedis) delete(key string) {
+	delete(f.hashes, key)
+	delete(f.expires, key)
+	f.versions[key]++
+}
+
+func (f *fakeRedis) do(args []string) (interface{}, error) {
+	f.commands = append(f.commands, args)
+	if len(args) == 0 {
+		return nil, RedisError("ERR empty command")
+	}
+	name, args := strings.ToUpper(args[0]), args[1:]
+	if name == "SCAN" {
+		return f.scan(args)
+	}
+	if len(args) == 0 {
+		return nil, RedisError("ERR wrong number of arguments")
+	}
+	key := args[0]
+	hash := f.hashes[key]
+	if !f.alive(key) {
+		hash = nil
+	}
+
+	switch name {
+	case "HGET":
+		if value, ok := hash[args[1]]; ok {
+			return value, nil
+		}
+		return nil, nil
+	case "HMGET":
+		values := make([]interface{}, len(args)-1)
+		for i, field := range args[1:] {
+			if value, ok := hash[field]; ok {
+				values[i] = value
+			}
+		}
+		return values, nil
+	case "HSET":
+		if len(args)%2 != 1 {
+			return nil, RedisError("ERR wrong number of arguments")
+		}
+		if hash == nil {
+			hash = make(map[string]string)
+			f.hashes[key] = hash
+		}
+		added := int64(0)
+		for i := 1; i < len(args); i += 2 {
+			if _, ok := hash[args[i]]; !ok {
+				added++
+			}
+			hash[args[i]] = args[i+1]
+		}
+		f.versions[key]++
+		return added, nil
+	case "HDEL":
+		removed := int64(0)
+		for _, field := range args[1:] {
+			if _, ok := hash[field]; ok {
+				delete(hash, field)
+				removed++
+			}
+		}
+		if removed > 0 {
+			f.versions[key]++
+		}
+		if hash != nil && len(hash) == 0 {
+			f.delete(key)
+		}
+		return removed, nil
+	case "DEL":
+		if hash == nil {
+			return int64(0), nil
+		}
+		f.delete(key)
+		return int64(1), nil
+	case "EXISTS":
+		if hash == nil {
+			return int64(0), nil
+		}
+		return int64(1), nil
+	case "PEXPIRE":
+		ms, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return nil, RedisError("ERR value is not an integer")
+		}
+		if hash == nil {
+			return int64(0), nil
+		}
+		f.expires[key] = f.now.Add(time.Duration(ms) * time.Millisecond)
+		f.versions[key]++
+		return int64(1), nil
+	case "PERSIST":
+		if _, ok := f.expires[key]; !ok || hash == nil {
+			return int64(0), nil
+		}
+		delete(f.expires, key)
+		return int64(1), nil
+	case "PTTL":
+		if hash == nil {
+			return int64(-2), nil
+		}
+		deadline, ok := f.expires[key]
+		if !ok {
+			return int64(-1), nil
+		}
+		return deadline.Sub(f.now).Milliseconds(), nil
+	}
+	return nil, RedisError(fmt.Sprintf("ERR unknown command '%s'", name))
+}
+
+// scan returns every matching key in one page, ordered for stable tests
+func (f *fakeRedis) scan(args []string) (interface{}, error) {
+	match, kind := "*", ""
+	for i := 1; i+1 < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			match = args[i+1]
+		case "TYPE":
+			kind = args[i+1]
+		}
+	}
+	if kind != "" && kind != "hash" {
+		return []interface{}{"0", []interface{}{}}, nil
+	}
+
+	var keys []string
+	for key := range f.hashes {
+		if !f.alive(key) {
+			continue
+		}
+		if ok, err := path.Match(match, key); err != nil {
+			return nil, RedisError("ERR invalid pattern")
+		} else if ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	items := make([]interface{}, len(keys))
+	for i, key := range keys {
+		items[i] = key
+	}
+	return []interface{}{"0", items}, nil
+}
+
+// fakeRedisSession implements WATCH/MULTI/EXEC on a fakeRedis
+type fakeRedisSession struct {
+	redis   *fakeRedis
+	watched map[string]int
+	queued  [][]string
+	multi   bool
+}
+
+func (s *fakeRedisSession) Do(ctx context.Context, args ...string) (interface{}, error) {
+	f := s.redis
+	switch strings.ToUpper(args[0]) {
+	case "WATCH":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if s.watched == nil {
+			s.watched = make(map[string]int)
+		}
+		for _, key := range args[1:] {
+			f.alive(key)
+			s.watched[key] = f.versions[key]
+		}
+		return "OK", nil
+	case "UNWATCH":
+		s.watched = nil
+		return "OK", nil
+	case "MULTI":
+		s.multi = true
+		return "OK", nil
+	case "DISCARD":
+		s.multi, s.queued, s.watched = false, nil, nil
+		return "OK", nil
+	case "EXEC":
+		if hook := f.beforeExec; hook != nil {
+			f.beforeExec = nil
+			hook()
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		queued, watched := s.queued, s.watched
+		s.multi, s.queued, s.watched = false, nil, nil
+		for key, version := range watched {
+			f.alive(key)
+			if f.versions[key] != version {
+				return nil, nil
+			}
+		}
+		results := make([]interface{}, len(queued))
+		for i, command := range queued {
+			reply, err := f.do(command)
+			if err != nil {
+				reply = err
+			}
+			results[i] = reply
+		}
+		return results, nil
+	}
+	if s.multi {
+		s.queued = append(s.queued, args)
+		return "QUEUED", nil
+	}
+	return f.Do(ctx, args...)
+}
+
+// plainRedis hides the Session method, for the non-transactional path
+type plainRedis struct {
+	redis *fakeRedis
+}
+
+func (p plainRedis) Do(ctx context.Context, args ...string) (interface{}, error) {
+	return p.redis.Do(ctx, args...)
+}
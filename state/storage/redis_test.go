@@ -0,0 +1,339 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestRedisKeyPrefix(t *testing.T) {
+	tests := []struct {
+		options *RedisStorageOptions
+		want    string
+	}{
+		{nil, "egobot:42"},
+		{&RedisStorageOptions{Prefix: "shop"}, "shop:42"},
+		{&RedisStorageOptions{Namespace: "bot1"}, "egobot:bot1:42"},
+		{&RedisStorageOptions{Prefix: "shop", Namespace: "bot1"}, "shop:bot1:42"},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		redis := newFakeRedis()
+		store := NewRedisStorage(redis, tt.options)
+		if got := store.Key("42"); got != tt.want {
+			t.Errorf("Key(42) = %q, want %q", got, tt.want)
+		}
+		if err := store.SetState(ctx, "42", "menu"); err != nil {
+			t.Fatal(err)
+		}
+		if got := redis.keys(); !reflect.DeepEqual(got, []string{tt.want}) {
+			t.Errorf("stored keys = %v, want [%s]", got, tt.want)
+		}
+	}
+}
+
+func TestRedisContext(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec, MsgpackCodec} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			ctx := context.Background()
+			store := NewRedisStorage(newFakeRedis(), &RedisStorageOptions{Codec: codec})
+
+			uc, err := store.GetContext(ctx, "1")
+			if err != nil || uc.State != "" || len(uc.Data) != 0 {
+				t.Fatalf("GetContext of a missing key = %+v, %v", uc, err)
+			}
+
+			if err := store.UpsertContext(ctx, "1", "order", map[string]interface{}{"item": "pen"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.UpsertData(ctx, "1", map[string]interface{}{"qty": 2}); err != nil {
+				t.Fatal(err)
+			}
+			// An empty state keeps the current one
+			if err := store.UpsertContext(ctx, "1", "", map[string]interface{}{"note": "gift"}); err != nil {
+				t.Fatal(err)
+			}
+
+			uc, err = store.GetContext(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if uc.State != "order" {
+				t.Errorf("state = %q, want order", uc.State)
+			}
+			if uc.Data["item"] != "pen" || uc.Data["note"] != "gift" || len(uc.Data) != 3 {
+				t.Errorf("data = %v", uc.Data)
+			}
+
+			if err := store.DeleteData(ctx, "1", "note"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.ClearState(ctx, "1"); err != nil {
+				t.Fatal(err)
+			}
+			uc, err = store.GetContext(ctx, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if uc.State != "" || len(uc.Data) != 2 || uc.Data["note"] != nil {
+				t.Errorf("context after DeleteData and ClearState = %+v", uc)
+			}
+
+			if err := store.ClearAll(ctx, "1"); err != nil {
+				t.Fatal(err)
+			}
+			data, err := store.GetData(ctx, "1")
+			if err != nil || len(data) != 0 {
+				t.Errorf("data after ClearAll = %v, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestRedisDeleteDataMissingKey(t *testing.T) {
+	redis := newFakeRedis()
+	store := NewRedisStorage(redis, nil)
+	if err := store.DeleteData(context.Background(), "1", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := redis.keys(); len(keys) != 0 {
+		t.Errorf("DeleteData created %v", keys)
+	}
+}
+
+func TestRedisTTLOption(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	store := NewRedisStorage(redis, &RedisStorageOptions{TTL: time.Hour})
+
+	if err := store.UpsertData(ctx, "1", map[string]interface{}{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := store.TTL(ctx, "1"); ttl != time.Hour {
+		t.Fatalf("TTL = %v, want 1h", ttl)
+	}
+
+	// Every write refreshes the TTL
+	redis.advance(30 * time.Minute)
+	if err := store.SetState(ctx, "1", "menu"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := store.TTL(ctx, "1"); ttl != time.Hour {
+		t.Errorf("TTL after a write = %v, want 1h", ttl)
+	}
+
+	redis.advance(time.Hour)
+	uc, err := store.GetContext(ctx, "1")
+	if err != nil || uc.State != "" || len(uc.Data) != 0 {
+		t.Errorf("context after the TTL = %+v, %v", uc, err)
+	}
+}
+
+func TestRedisExpire(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	store := NewRedisStorage(redis, &RedisStorageOptions{TTL: time.Hour})
+
+	// Missing keys are not created
+	if err := store.Expire(ctx, "1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if keys := redis.keys(); len(keys) != 0 {
+		t.Fatalf("Expire created %v", keys)
+	}
+
+	if err := store.SetState(ctx, "1", "menu"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Expire(ctx, "1", 10*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := store.TTL(ctx, "1"); ttl != 10*time.Minute {
+		t.Fatalf("TTL = %v, want 10m", ttl)
+	}
+
+	// A per-key TTL is not refreshed by writes
+	redis.advance(5 * time.Minute)
+	if err := store.UpsertData(ctx, "1", map[string]interface{}{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := store.TTL(ctx, "1"); ttl != 5*time.Minute {
+		t.Errorf("TTL after a write = %v, want 5m", ttl)
+	}
+	data, err := store.GetData(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data[redisTTLField]; ok || len(data) != 1 {
+		t.Errorf("data = %v, want the TTL kept out of it", data)
+	}
+
+	// Cancelling falls back to the TTL option
+	if err := store.Expire(ctx, "1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := store.TTL(ctx, "1"); ttl != time.Hour {
+		t.Errorf("TTL after cancelling = %v, want 1h", ttl)
+	}
+
+	if err := store.Expire(ctx, "1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	redis.advance(time.Minute)
+	if state, _ := store.GetState(ctx, "1"); state != "" {
+		t.Errorf("state after expiry = %q", state)
+	}
+}
+
+func TestRedisExpireWithoutTTLOption(t *testing.T) {
+	ctx := context.Background()
+	store := NewRedisStorage(newFakeRedis(), nil)
+
+	if err := store.SetState(ctx, "1", "menu"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := store.TTL(ctx, "1"); ttl != 0 {
+		t.Fatalf("TTL = %v, want none", ttl)
+	}
+	if err := store.Expire(ctx, "1", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Expire(ctx, "1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, _ := store.TTL(ctx, "1"); ttl != 0 {
+		t.Errorf("TTL after cancelling = %v, want none", ttl)
+	}
+}
+
+func TestRedisUpdateRetriesConflicts(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	store := NewRedisStorage(redis, nil)
+	if err := store.UpsertData(ctx, "1", map[string]interface{}{"count": 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another writer changes the key between WATCH and EXEC
+	redis.beforeExec = func() {
+		redis.Do(ctx, "HSET", store.Key("1"), redisStateField, "other")
+	}
+	runs := 0
+	err := store.Update(ctx, "1", func(uc *UserContext) error {
+		runs++
+		count, _ := uc.Data["count"].(float64)
+		uc.Data["count"] = count + 1
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs != 2 {
+		t.Errorf("fn ran %d times, want 2", runs)
+	}
+	uc, _ := store.GetContext(ctx, "1")
+	if uc.State != "other" || uc.Data["count"] != float64(2) {
+		t.Errorf("context = %+v", uc)
+	}
+}
+
+func TestRedisUpdateWithoutSession(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	store := NewRedisStorage(plainRedis{redis}, nil)
+
+	if err := store.UpsertContext(ctx, "1", "menu", map[string]interface{}{"a": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	err := store.Update(ctx, "1", func(uc *UserContext) error {
+		uc.State = ""
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc, _ := store.GetContext(ctx, "1")
+	if uc.State != "" || uc.Data["a"] != "b" {
+		t.Errorf("context = %+v", uc)
+	}
+	for _, command := range redis.commands {
+		if command[0] == "WATCH" || command[0] == "MULTI" {
+			t.Errorf("sent %v without a session", command)
+		}
+	}
+}
+
+func TestRedisUpdateError(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	store := NewRedisStorage(redis, nil)
+	errStop := errors.New("stop")
+	err := store.Update(ctx, "1", func(uc *UserContext) error {
+		uc.State = "menu"
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Update error = %v, want %v", err, errStop)
+	}
+	if keys := redis.keys(); len(keys) != 0 {
+		t.Errorf("failed Update wrote %v", keys)
+	}
+}
+
+func TestRedisScanPrefix(t *testing.T) {
+	ctx := context.Background()
+	redis := newFakeRedis()
+	store := NewRedisStorage(redis, &RedisStorageOptions{Prefix: "bot[1]", Namespace: "a*"})
+	other := NewRedisStorage(redis, &RedisStorageOptions{Prefix: "bot[1]", Namespace: "ab"})
+
+	for _, key := range []string{"1", "2", "-100:3"} {
+		if err := store.SetState(ctx, key, "menu"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := other.SetState(ctx, "9", "menu"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, next, err := store.Scan(ctx, "", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(keys)
+	if next != "" || !reflect.DeepEqual(keys, []string{"-100:3", "1", "2"}) {
+		t.Errorf("Scan = %v, %q", keys, next)
+	}
+
+	keys, _, err = store.Scan(ctx, "", "-100:*", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys, []string{"-100:3"}) {
+		t.Errorf("Scan(-100:*) = %v", keys)
+	}
+
+	if _, _, err := store.Scan(ctx, "", "[", 0); err == nil {
+		t.Error("Scan with an invalid pattern succeeded")
+	}
+}
+
+func TestRedisCountByState(t *testing.T) {
+	ctx := context.Background()
+	store := NewRedisStorage(newFakeRedis(), nil)
+	store.SetState(ctx, "1", "menu")
+	store.SetState(ctx, "2", "menu")
+	store.SetState(ctx, "3", "order")
+	store.UpsertData(ctx, "4", map[string]interface{}{"a": 1})
+
+	counts, err := store.CountByState(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"menu": 2, "order": 1, "": 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("CountByState = %v, want %v", counts, want)
+	}
+}
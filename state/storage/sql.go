@@ -0,0 +1,481 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLDialect selects the SQL flavour of a SQLStorage
+type SQLDialect int
+
+const (
+	// DialectSQLite targets SQLite 3.24+ (upserts with ON CONFLICT)
+	DialectSQLite SQLDialect = iota
+	// DialectPostgres targets PostgreSQL 9.5+, storing data as JSONB
+	DialectPostgres
+)
+
+func (d SQLDialect) String() string {
+	switch d {
+	case DialectSQLite:
+		return "sqlite"
+	case DialectPostgres:
+		return "postgres"
+	}
+	return "unknown"
+}
+
+// rebind replaces ? placeholders with the dialect's placeholders
+func (d SQLDialect) rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+	var builder strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+func (d SQLDialect) jsonType() string {
+	if d == DialectPostgres {
+		return "JSONB"
+	}
+	return "TEXT"
+}
+
+// jsonValue wraps a placeholder holding a JSON document
+func (d SQLDialect) jsonValue(placeholder string) string {
+	if d == DialectPostgres {
+		return "CAST(" + placeholder + " AS JSONB)"
+	}
+	return placeholder
+}
+
+// SQLStorageOptions configures a SQLStorage
+type SQLStorageOptions struct {
+	Dialect        SQLDialect
+	Table          string // Table name (default "egobot_states"); migrations go to "<table>_migrations"
+	SkipMigrations bool   // Don't create or migrate the schema in NewSQLStorage
+}
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sqlMigration is one schema version. Statements must be idempotent.
+type sqlMigration struct {
+	version     int
+	description string
+	statements  func(d SQLDialect, table string) []string
+}
+
+var sqlMigrations = []sqlMigration{
+	{
+		version:     1,
+		description: "create states table",
+		statements: func(d SQLDialect, table string) []string {
+			return []string{fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	key TEXT PRIMARY KEY,
+	state TEXT NOT NULL DEFAULT '',
+	data %s NOT NULL,
+	updated_at BIGINT NOT NULL
+)`, table, d.jsonType())}
+		},
+	},
+	{
+		version:     2,
+		description: "index states by state",
+		statements: func(d SQLDialect, table string) []string {
+			return []string{fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_state_idx ON %s (state)", table, table)}
+		},
+	},
+}
+
+// SQLStorage implements BaseStorage over database/sql for SQLite and
+// PostgreSQL. Bring your own driver and *sql.DB:
+//
+//	db, err := sql.Open("sqlite3", "bot.db") // or "pgx", "postgres", ...
+//	store, err := storage.NewSQLStorage(ctx, db, &storage.SQLStorageOptions{
+//		Dialect: storage.DialectSQLite,
+//	})
+//	bot.SetStorage(store)
+//
+// Every user is a row with the state and the data as a JSON document.
+// The schema is created and migrated on start, tracked in a migrations table.
+type SQLStorage struct {
+	db      *sql.DB
+	dialect SQLDialect
+	table   string
+}
+
+// NewSQLStorage creates a SQL storage on db and migrates its schema.
+// Pass nil options to use SQLite and the default table.
+func NewSQLStorage(ctx context.Context, db *sql.DB, options *SQLStorageOptions) (*SQLStorage, error) {
+	if options == nil {
+		options = &SQLStorageOptions{}
+	}
+	table := options.Table
+	if table == "" {
+		table = "egobot_states"
+	}
+	if !sqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name %q", table)
+	}
+	if options.Dialect != DialectSQLite && options.Dialect != DialectPostgres {
+		return nil, fmt.Errorf("unsupported SQL dialect %d", options.Dialect)
+	}
+
+	s := &SQLStorage{
+		db:      db,
+		dialect: options.Dialect,
+		table:   table,
+	}
+	if !options.SkipMigrations {
+		if err := s.Migrate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// DB returns the underlying database
+func (s *SQLStorage) DB() *sql.DB {
+	return s.db
+}
+
+// Dialect returns the SQL dialect in use
+func (s *SQLStorage) Dialect() SQLDialect {
+	return s.dialect
+}
+
+func (s *SQLStorage) migrationsTable() string {
+	return s.table + "_migrations"
+}
+
+// Migrate creates the schema and applies pending migrations
+func (s *SQLStorage) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version INTEGER PRIMARY KEY,
+	description TEXT NOT NULL,
+	applied_at BIGINT NOT NULL
+)`, s.migrationsTable()))
+	if err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	for _, migration := range sqlMigrations {
+		if migration.version <= current {
+			continue
+		}
+		if err := s.applyMigration(ctx, migration); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", migration.version, migration.description, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLStorage) applyMigration(ctx context.Context, migration sqlMigration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range migration.statements(s.dialect, s.table) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	// Another instance may have applied the migration concurrently
+	_, err = tx.ExecContext(ctx, s.dialect.rebind(fmt.Sprintf(
+		"INSERT INTO %s (version, description, applied_at) VALUES (?, ?, ?) ON CONFLICT (version) DO NOTHING",
+		s.migrationsTable(),
+	)), migration.version, migration.description, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SchemaVersion returns the latest applied migration, 0 if none
+func (s *SQLStorage) SchemaVersion(ctx context.Context) (int, error) {
+	var version sql.NullInt64
+	err := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT MAX(version) FROM %s", s.migrationsTable())).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+func (s *SQLStorage) query(query string) string {
+	return s.dialect.rebind(fmt.Sprintf(query, s.table))
+}
+
+func decodeSQLData(raw []byte) (map[string]interface{}, error) {
+	data, err := JSONCodec.Decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode user data: %w", err)
+	}
+	return data, nil
+}
+
+// GetContext retrieves the complete user context (state + data)
+func (s *SQLStorage) GetContext(ctx context.Context, key string) (*UserContext, error) {
+	var (
+		state string
+		raw   []byte
+	)
+	err := s.db.QueryRowContext(ctx, s.query("SELECT state, data FROM %s WHERE key = ?"), key).Scan(&state, &raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &UserContext{Data: make(map[string]interface{})}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := decodeSQLData(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &UserContext{State: state, Data: data}, nil
+}
+
+// GetState retrieves only the state for a user
+func (s *SQLStorage) GetState(ctx context.Context, key string) (string, error) {
+	var state string
+	err := s.db.QueryRowContext(ctx, s.query("SELECT state FROM %s WHERE key = ?"), key).Scan(&state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return state, err
+}
+
+// SetState sets the state for a user
+func (s *SQLStorage) SetState(ctx context.Context, key string, state string) error {
+	_, err := s.db.ExecContext(ctx, s.query(`INSERT INTO %[1]s (key, state, data, updated_at)
+VALUES (?, ?, `+s.dialect.jsonValue("?")+`, ?)
+ON CONFLICT (key) DO UPDATE SET state = excluded.state, updated_at = excluded.updated_at`),
+		key, state, "{}", time.Now().UnixMilli())
+	return err
+}
+
+// ClearState clears the state for a user
+func (s *SQLStorage) ClearState(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.query("UPDATE %s SET state = '', updated_at = ? WHERE key = ?"), time.Now().UnixMilli(), key)
+	return err
+}
+
+// GetData retrieves only the data for a user
+func (s *SQLStorage) GetData(ctx context.Context, key string) (map[string]interface{}, error) {
+	var raw []byte
+	err := s.db.QueryRowContext(ctx, s.query("SELECT data FROM %s WHERE key = ?"), key).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return make(map[string]interface{}), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeSQLData(raw)
+}
+
+// UpsertData updates or inserts data for a user
+func (s *SQLStorage) UpsertData(ctx context.Context, key string, data map[string]interface{}) error {
+	return s.merge(ctx, key, "", data)
+}
+
+// ClearData clears all data for a user
+func (s *SQLStorage) ClearData(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.query("UPDATE %[1]s SET data = "+s.dialect.jsonValue("?")+", updated_at = ? WHERE key = ?"),
+		"{}", time.Now().UnixMilli(), key)
+	return err
+}
+
+// DeleteData removes fields from the data of a user
+func (s *SQLStorage) DeleteData(ctx context.Context, key string, fields ...string) error {
+	// Don't create an empty context for a missing key
+	uc, err := s.GetContext(ctx, key)
+	if err != nil || (uc.State == "" && len(uc.Data) == 0) {
+		return err
+	}
+	return s.Update(ctx, key, func(uc *UserContext) error {
+		for _, field := range fields {
+			delete(uc.Data, field)
+		}
+		return nil
+	})
+}
+
+// UpsertContext updates or inserts the complete user context
+func (s *SQLStorage) UpsertContext(ctx context.Context, key string, state string, data map[string]interface{}) error {
+	return s.merge(ctx, key, state, data)
+}
+
+// ClearAll removes all data and state for a user
+func (s *SQLStorage) ClearAll(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.query("DELETE FROM %s WHERE key = ?"), key)
+	return err
+}
+
+// Close closes the database
+func (s *SQLStorage) Close() error {
+	return s.db.Close()
+}
+
+// merge sets state (if not empty) and merges data into the stored data.
+// PostgreSQL merges JSONB documents in the upsert itself; SQLite goes
+// through Update.
+func (s *SQLStorage) merge(ctx context.Context, key, state string, data map[string]interface{}) error {
+	if s.dialect != DialectPostgres {
+		return s.Update(ctx, key, func(uc *UserContext) error {
+			if state != "" {
+				uc.State = state
+			}
+			for k, v := range data {
+				uc.Data[k] = v
+			}
+			return nil
+		})
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode user data: %w", err)
+	}
+	if data == nil {
+		raw = []byte("{}")
+	}
+	_, err = s.db.ExecContext(ctx, s.query(`INSERT INTO %[1]s (key, state, data, updated_at)
+VALUES (?, ?, `+s.dialect.jsonValue("?")+`, ?)
+ON CONFLICT (key) DO UPDATE SET
+	state = CASE WHEN excluded.state = '' THEN %[1]s.state ELSE excluded.state END,
+	data = %[1]s.data || excluded.data,
+	updated_at = excluded.updated_at`), key, state, string(raw), time.Now().UnixMilli())
+	return err
+}
+
+// Update applies fn to the context of key in a transaction holding the
+// row lock (SELECT ... FOR UPDATE on PostgreSQL, the database write lock on
+// SQLite). The row is created first so concurrent first writes serialize too.
+func (s *SQLStorage) Update(ctx context.Context, key string, fn UpdateFunc) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixMilli()
+	_, err = tx.ExecContext(ctx, s.query(`INSERT INTO %[1]s (key, state, data, updated_at)
+VALUES (?, '', `+s.dialect.jsonValue("?")+`, ?)
+ON CONFLICT (key) DO NOTHING`), key, "{}", now)
+	if err != nil {
+		return err
+	}
+
+	selectQuery := "SELECT state, data FROM %s WHERE key = ?"
+	if s.dialect == DialectPostgres {
+		selectQuery += " FOR UPDATE"
+	}
+	var (
+		state string
+		raw   []byte
+	)
+	if err := tx.QueryRowContext(ctx, s.query(selectQuery), key).Scan(&state, &raw); err != nil {
+		return err
+	}
+	data, err := decodeSQLData(raw)
+	if err != nil {
+		return err
+	}
+
+	uc := &UserContext{State: state, Data: data}
+	if err := fn(uc); err != nil {
+		return err
+	}
+	if uc.Data == nil {
+		uc.Data = make(map[string]interface{})
+	}
+	encoded, err := json.Marshal(uc.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode user data: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, s.query("UPDATE %[1]s SET state = ?, data = "+s.dialect.jsonValue("?")+", updated_at = ? WHERE key = ?"),
+		uc.State, string(encoded), now, key)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Scan returns a page of keys in key order (see IterableStorage).
+// Keys are paged by the database and matched against match afterwards, so
+// pages may hold fewer than count keys.
+func (s *SQLStorage) Scan(ctx context.Context, cursor string, match string, count int) ([]string, string, error) {
+	if err := validateMatch(match); err != nil {
+		return nil, "", err
+	}
+	if count <= 0 {
+		count = DefaultScanCount
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.query("SELECT key FROM %s WHERE key > ? ORDER BY key LIMIT ?"), cursor, count)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var keys []string
+	var last string
+	scanned := 0
+	for rows.Next() {
+		if err := rows.Scan(&last); err != nil {
+			return nil, "", err
+		}
+		scanned++
+		if matchKey(match, last) {
+			keys = append(keys, last)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+	if scanned < count {
+		last = ""
+	}
+	return keys, last, nil
+}
+
+// CountByState returns the number of keys in each state
+func (s *SQLStorage) CountByState(ctx context.Context) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, s.query("SELECT state, COUNT(*) FROM %s GROUP BY state"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var state string
+		var count int
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, err
+		}
+		counts[state] = count
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,181 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// DataCodec converts typed values to and from the values stored in user
+// data (maps, slices, strings, float64, bool and nil)
+type DataCodec interface {
+	// Encode converts value into a storable value
+	Encode(value interface{}) (interface{}, error)
+	// Decode converts a stored value into target, a non-nil pointer
+	Decode(stored interface{}, target interface{}) error
+}
+
+// JSONDataCodec converts values through encoding/json, honouring json tags.
+// This is the default codec.
+type JSONDataCodec struct{}
+
+// Encode converts value into its generic JSON representation
+func (JSONDataCodec) Encode(value interface{}) (interface{}, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var stored interface{}
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// Decode converts a stored value into target
+func (JSONDataCodec) Decode(stored interface{}, target interface{}) error {
+	raw, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, target)
+}
+
+// SetDataCodec sets the codec used by the typed data helpers (default JSONDataCodec).
+// Pass nil to restore the default.
+func (m *Manager) SetDataCodec(codec DataCodec) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dataCodec = codec
+}
+
+// DataCodec returns the codec used by the typed data helpers
+func (m *Manager) DataCodec() DataCodec {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.dataCodec == nil {
+		return JSONDataCodec{}
+	}
+	return m.dataCodec
+}
+
+// DataCodec returns the codec used by the typed data helpers for the user
+func (u *UserStateManager) DataCodec() DataCodec {
+	if u.manager == nil {
+		return JSONDataCodec{}
+	}
+	return u.manager.DataCodec()
+}
+
+// DecodeData decodes user data into a T with codec (JSONDataCodec if nil)
+func DecodeData[T any](codec DataCodec, data map[string]interface{}) (T, error) {
+	var value T
+	if codec == nil {
+		codec = JSONDataCodec{}
+	}
+	if data == nil {
+		return value, nil
+	}
+	if err := codec.Decode(data, &value); err != nil {
+		return value, fmt.Errorf("failed to decode state data: %w", err)
+	}
+	return value, nil
+}
+
+// EncodeData encodes value into user data fields with codec (JSONDataCodec if nil).
+// value must encode to an object, e.g. a struct or a map.
+func EncodeData[T any](codec DataCodec, value T) (map[string]interface{}, error) {
+	if codec == nil {
+		codec = JSONDataCodec{}
+	}
+	stored, err := codec.Encode(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode state data: %w", err)
+	}
+	if stored == nil {
+		return map[string]interface{}{}, nil
+	}
+	fields, ok := stored.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("failed to encode state data: %T does not encode to an object", value)
+	}
+	return fields, nil
+}
+
+// GetDataAs decodes the user's data into a T, typically a struct whose
+// fields map to data keys:
+//
+//	type Order struct {
+//		Product  string `json:"product"`
+//		Quantity int    `json:"quantity"`
+//	}
+//
+//	order, err := state.GetDataAs[Order](ctx, userState)
+func GetDataAs[T any](ctx context.Context, um *UserStateManager) (T, error) {
+	data, err := um.GetData(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return DecodeData[T](um.DataCodec(), data)
+}
+
+// SetDataFrom encodes value and merges its fields into the user's data.
+// Keys not produced by value (including omitempty fields left empty) are kept.
+func SetDataFrom[T any](ctx context.Context, um *UserStateManager, value T) error {
+	fields, err := EncodeData(um.DataCodec(), value)
+	if err != nil {
+		return err
+	}
+	return um.SetData(ctx, fields)
+}
+
+// UpdateDataAs decodes the user's data into a T, lets fn modify it and
+// merges the result back, atomically (see UserStateManager.Update)
+func UpdateDataAs[T any](ctx context.Context, um *UserStateManager, fn func(value *T) error) error {
+	codec := um.DataCodec()
+	return um.UpdateData(ctx, func(data map[string]interface{}) error {
+		value, err := DecodeData[T](codec, data)
+		if err != nil {
+			return err
+		}
+		if err := fn(&value); err != nil {
+			return err
+		}
+		fields, err := EncodeData(codec, value)
+		if err != nil {
+			return err
+		}
+		for k, v := range fields {
+			data[k] = v
+		}
+		return nil
+	})
+}
+
+// GetDataValueAs decodes a single data value into a T.
+// Returns false if the key is not set.
+func GetDataValueAs[T any](ctx context.Context, um *UserStateManager, key string) (T, bool, error) {
+	var value T
+	data, err := um.GetData(ctx)
+	if err != nil {
+		return value, false, err
+	}
+	stored, ok := data[key]
+	if !ok {
+		return value, false, nil
+	}
+	if err := um.DataCodec().Decode(stored, &value); err != nil {
+		return value, false, fmt.Errorf("failed to decode state data %q: %w", key, err)
+	}
+	return value, true, nil
+}
+
+// SetDataValueFrom encodes value and stores it under key
+func SetDataValueFrom[T any](ctx context.Context, um *UserStateManager, key string, value T) error {
+	stored, err := um.DataCodec().Encode(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode state data %q: %w", key, err)
+	}
+	return um.SetDataValue(ctx, key, stored)
+}
@@ -0,0 +1,483 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/erfjab/egobot/core"
+	"github.com/erfjab/egobot/models"
+)
+
+var ErrCalendarNoSender = errors.New("calendar: update has no sender to store the selection for")
+
+// CalendarLocale holds the names used to render a calendar
+type CalendarLocale struct {
+	Months       [12]string // January first
+	Weekdays     [7]string  // Sunday first, indexed by time.Weekday
+	FirstWeekday time.Weekday
+}
+
+var (
+	CalendarLocaleEN = CalendarLocale{
+		Months:       [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		Weekdays:     [7]string{"Su", "Mo", "Tu", "We", "Th", "Fr", "Sa"},
+		FirstWeekday: time.Monday,
+	}
+	CalendarLocaleRU = CalendarLocale{
+		Months:       [12]string{"Январь", "Февраль", "Март", "Апрель", "Май", "Июнь", "Июль", "Август", "Сентябрь", "Октябрь", "Ноябрь", "Декабрь"},
+		Weekdays:     [7]string{"Вс", "Пн", "Вт", "Ср", "Чт", "Пт", "Сб"},
+		FirstWeekday: time.Monday,
+	}
+	CalendarLocaleFA = CalendarLocale{
+		Months:       [12]string{"ژانویه", "فوریه", "مارس", "آوریل", "مه", "ژوئن", "ژوئیه", "اوت", "سپتامبر", "اکتبر", "نوامبر", "دسامبر"},
+		Weekdays:     [7]string{"ی", "د", "س", "چ", "پ", "ج", "ش"},
+		FirstWeekday: time.Saturday,
+	}
+)
+
+// CalendarSelectFunc handles the selected date (and time, if the time picker is enabled)
+type CalendarSelectFunc func(ctx *core.Context, selected time.Time) error
+
+// calendarCallback is the packed callback payload of calendar buttons.
+// Value holds a compact timestamp since ":" is the callback separator.
+type calendarCallback struct {
+	CallbackData `prefix:"cal"`
+	Name         string
+	Action       string
+	Value        string
+}
+
+const (
+	calendarActionMonth  = "m"
+	calendarActionDay    = "d"
+	calendarActionHour   = "h"
+	calendarActionMinute = "i"
+	calendarActionNoop   = "n"
+
+	calendarMonthLayout  = "200601"
+	calendarDayLayout    = "20060102"
+	calendarHourLayout   = "2006010215"
+	calendarMinuteLayout = "200601021504"
+)
+
+// Calendar is an inline date picker with month navigation and an optional
+// hour/minute picker step. All navigation is carried in the callback data.
+//
+// Example:
+//
+//	calendar := tools.NewCalendar("booking").
+//		Locale(tools.CalendarLocaleEN).
+//		MinDate(time.Now()).
+//		WithTime(1, 15).
+//		OnSelect(func(ctx *core.Context, selected time.Time) error {
+//			_, err := ctx.EditText("Booked for " + selected.Format(time.RFC1123))
+//			return err
+//		})
+//	calendar.Register(bot.RegisterCommands)
+//	bot.OnCommand("book", func(bot *core.Bot, update *models.Update, ctx *core.Context) error {
+//		return calendar.Start(ctx, time.Now())
+//	})
+type Calendar struct {
+	name       string
+	text       string
+	parseMode  string
+	locale     CalendarLocale
+	location   *time.Location
+	minDate    time.Time
+	maxDate    time.Time
+	disabled   func(day time.Time) bool
+	hourStep   int
+	minuteStep int
+	storeKey   string
+	onSelect   CalendarSelectFunc
+}
+
+// NewCalendar creates a new calendar. name must be unique among the bot's
+// calendars and must not contain ":"; the Markup methods return an error
+// otherwise.
+func NewCalendar(name string) *Calendar {
+	return &Calendar{
+		name:     name,
+		locale:   CalendarLocaleEN,
+		location: time.UTC,
+	}
+}
+
+// Name returns the name of this calendar
+func (c *Calendar) Name() string {
+	return c.name
+}
+
+// Text sets the message text shown above the calendar
+func (c *Calendar) Text(text string) *Calendar {
+	c.text = text
+	return c
+}
+
+// ParseMode sets the parse mode of the calendar text
+func (c *Calendar) ParseMode(parseMode string) *Calendar {
+	c.parseMode = parseMode
+	return c
+}
+
+// Locale sets month and weekday names and the first day of the week
+func (c *Calendar) Locale(locale CalendarLocale) *Calendar {
+	c.locale = locale
+	return c
+}
+
+// Location sets the time zone of the selected time (default UTC)
+func (c *Calendar) Location(location *time.Location) *Calendar {
+	if location != nil {
+		c.location = location
+	}
+	return c
+}
+
+// MinDate sets the earliest selectable time
+func (c *Calendar) MinDate(min time.Time) *Calendar {
+	c.minDate = min
+	return c
+}
+
+// MaxDate sets the latest selectable time
+func (c *Calendar) MaxDate(max time.Time) *Calendar {
+	c.maxDate = max
+	return c
+}
+
+// DisableDays sets a function reporting days that cannot be selected
+func (c *Calendar) DisableDays(disabled func(day time.Time) bool) *Calendar {
+	c.disabled = disabled
+	return c
+}
+
+// WithTime adds an hour picker and, if minuteStep > 0, a minute picker after the day is selected
+func (c *Calendar) WithTime(hourStep, minuteStep int) *Calendar {
+	if hourStep <= 0 {
+		hourStep = 1
+	}
+	c.hourStep = hourStep
+	c.minuteStep = minuteStep
+	return c
+}
+
+// StoreIn stores the selected time in the user's state data under key.
+// Read it back with CalendarTime.
+func (c *Calendar) StoreIn(key string) *Calendar {
+	c.storeKey = key
+	return c
+}
+
+// OnSelect sets the handler receiving the selected time
+func (c *Calendar) OnSelect(handler CalendarSelectFunc) *Calendar {
+	c.onSelect = handler
+	return c
+}
+
+// Register registers the callback handler for navigation and selection
+func (c *Calendar) Register(r *core.RegisterCommands, opts ...interface{}) {
+	r.OnCallbackStruct(&calendarCallback{Name: c.name}, c.handleCallback, opts...)
+}
+
+// Start sends the calendar for the month of month as a new message
+func (c *Calendar) Start(ctx *core.Context, month time.Time) error {
+	markup, err := c.MonthMarkup(month)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.Reply(c.text, c.messageOptions(markup)...)
+	return err
+}
+
+// Show edits the current message to show the month of month
+func (c *Calendar) Show(ctx *core.Context, month time.Time) error {
+	markup, err := c.MonthMarkup(month)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.EditText(c.text, c.messageOptions(markup)...)
+	return err
+}
+
+// MonthMarkup builds the keyboard of a month
+func (c *Calendar) MonthMarkup(month time.Time) (*models.InlineKeyboardMarkup, error) {
+	if err := c.checkName(); err != nil {
+		return nil, err
+	}
+	month = month.In(c.location)
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, c.location)
+	prev := first.AddDate(0, -1, 0)
+	next := first.AddDate(0, 1, 0)
+
+	kb := NewInlineKeyboard()
+
+	header := make([]models.InlineKeyboardButton, 0, 3)
+	if c.minDate.IsZero() || !first.Add(-time.Nanosecond).Before(c.minDate) {
+		header = append(header, c.button("‹", calendarActionMonth, prev.Format(calendarMonthLayout)))
+	} else {
+		header = append(header, c.noop(" "))
+	}
+	header = append(header, c.noop(fmt.Sprintf("%s %d", c.locale.Months[first.Month()-1], first.Year())))
+	if c.maxDate.IsZero() || !next.After(c.maxDate) {
+		header = append(header, c.button("›", calendarActionMonth, next.Format(calendarMonthLayout)))
+	} else {
+		header = append(header, c.noop(" "))
+	}
+	kb.Row(header...)
+
+	weekdays := make([]models.InlineKeyboardButton, 0, 7)
+	for i := 0; i < 7; i++ {
+		weekdays = append(weekdays, c.noop(c.locale.Weekdays[(int(c.locale.FirstWeekday)+i)%7]))
+	}
+	kb.Row(weekdays...)
+
+	offset := (int(first.Weekday()) - int(c.locale.FirstWeekday) + 7) % 7
+	row := make([]models.InlineKeyboardButton, 0, 7)
+	for i := 0; i < offset; i++ {
+		row = append(row, c.noop(" "))
+	}
+	for day := first; day.Month() == first.Month(); day = day.AddDate(0, 0, 1) {
+		if c.dayAvailable(day) {
+			row = append(row, c.button(strconv.Itoa(day.Day()), calendarActionDay, day.Format(calendarDayLayout)))
+		} else {
+			row = append(row, c.noop("·"))
+		}
+		if len(row) == 7 {
+			kb.Row(row...)
+			row = make([]models.InlineKeyboardButton, 0, 7)
+		}
+	}
+	if len(row) > 0 {
+		for len(row) < 7 {
+			row = append(row, c.noop(" "))
+		}
+		kb.Row(row...)
+	}
+	return kb.Build(), nil
+}
+
+// HourMarkup builds the hour picker keyboard of a day
+func (c *Calendar) HourMarkup(day time.Time) (*models.InlineKeyboardMarkup, error) {
+	if err := c.checkName(); err != nil {
+		return nil, err
+	}
+	day = day.In(c.location)
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, c.location)
+
+	kb := NewInlineKeyboard()
+	kb.Row(c.noop(fmt.Sprintf("%d %s %d", start.Day(), c.locale.Months[start.Month()-1], start.Year())))
+
+	var row []models.InlineKeyboardButton
+	for hour := 0; hour < 24; hour += c.hourStep {
+		// Built from the wall clock so DST days keep their labels; hours
+		// skipped by a DST change normalize to another hour and are disabled
+		slot := time.Date(start.Year(), start.Month(), start.Day(), hour, 0, 0, 0, c.location)
+		end := time.Date(start.Year(), start.Month(), start.Day(), hour+1, 0, 0, 0, c.location).Add(-time.Nanosecond)
+		label := fmt.Sprintf("%02d:00", hour)
+		if slot.Hour() == hour && c.rangeAvailable(slot, end) {
+			row = append(row, c.button(label, calendarActionHour, slot.Format(calendarHourLayout)))
+		} else {
+			row = append(row, c.noop("·"))
+		}
+		if len(row) == 4 {
+			kb.Row(row...)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		kb.Row(row...)
+	}
+	kb.Row(c.button("‹", calendarActionMonth, start.Format(calendarMonthLayout)))
+	return kb.Build(), nil
+}
+
+// MinuteMarkup builds the minute picker keyboard of an hour
+func (c *Calendar) MinuteMarkup(hour time.Time) (*models.InlineKeyboardMarkup, error) {
+	if err := c.checkName(); err != nil {
+		return nil, err
+	}
+	hour = hour.In(c.location)
+	start := time.Date(hour.Year(), hour.Month(), hour.Day(), hour.Hour(), 0, 0, 0, c.location)
+	step := c.minuteStep
+	if step <= 0 {
+		step = 60
+	}
+
+	kb := NewInlineKeyboard()
+	kb.Row(c.noop(fmt.Sprintf("%d %s %d", start.Day(), c.locale.Months[start.Month()-1], start.Year())))
+
+	var row []models.InlineKeyboardButton
+	for minute := 0; minute < 60; minute += step {
+		slot := time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), minute, 0, 0, c.location)
+		label := fmt.Sprintf("%02d:%02d", start.Hour(), minute)
+		if c.rangeAvailable(slot, slot) {
+			row = append(row, c.button(label, calendarActionMinute, slot.Format(calendarMinuteLayout)))
+		} else {
+			row = append(row, c.noop("·"))
+		}
+		if len(row) == 4 {
+			kb.Row(row...)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		kb.Row(row...)
+	}
+	kb.Row(c.button("‹", calendarActionDay, start.Format(calendarDayLayout)))
+	return kb.Build(), nil
+}
+
+func (c *Calendar) dayAvailable(day time.Time) bool {
+	if c.disabled != nil && c.disabled(day) {
+		return false
+	}
+	return c.rangeAvailable(day, day.AddDate(0, 0, 1).Add(-time.Nanosecond))
+}
+
+// dayOf returns the start of the day of t
+func (c *Calendar) dayOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.location)
+}
+
+// rangeAvailable reports whether [from, to] overlaps the min/max window
+func (c *Calendar) rangeAvailable(from, to time.Time) bool {
+	if !c.minDate.IsZero() && to.Before(c.minDate) {
+		return false
+	}
+	if !c.maxDate.IsZero() && from.After(c.maxDate) {
+		return false
+	}
+	return true
+}
+
+// checkName reports names the callback data can't carry, e.g. containing ":".
+// Actions and values are fixed digits, so buttons can't fail once it passes.
+func (c *Calendar) checkName() error {
+	if _, err := PackCallback(&calendarCallback{Name: c.name, Action: calendarActionNoop}); err != nil {
+		return fmt.Errorf("calendar %q: %w", c.name, err)
+	}
+	return nil
+}
+
+func (c *Calendar) button(text, action, value string) models.InlineKeyboardButton {
+	return MustCallbackButton(text, &calendarCallback{Name: c.name, Action: action, Value: value})
+}
+
+func (c *Calendar) noop(text string) models.InlineKeyboardButton {
+	return c.button(text, calendarActionNoop, "")
+}
+
+func (c *Calendar) messageOptions(markup *models.InlineKeyboardMarkup) []core.MessageOption {
+	opts := []core.MessageOption{core.WithReplyMarkup(markup)}
+	if c.parseMode != "" {
+		opts = append(opts, core.WithParseMode(c.parseMode))
+	}
+	return opts
+}
+
+func (c *Calendar) handleCallback(_ *core.Bot, _ *models.Update, ctx *core.Context) error {
+	payload, ok := core.GetCallbackStruct[*calendarCallback](ctx)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	switch payload.Action {
+	case calendarActionMonth:
+		err = c.onMonth(ctx, payload.Value)
+	case calendarActionDay:
+		err = c.onDay(ctx, payload.Value)
+	case calendarActionHour:
+		err = c.onHour(ctx, payload.Value)
+	case calendarActionMinute:
+		err = c.onMinute(ctx, payload.Value)
+	}
+	if err != nil {
+		return err
+	}
+	if !ctx.Answered() {
+		return ctx.Answer("")
+	}
+	return nil
+}
+
+func (c *Calendar) onMonth(ctx *core.Context, value string) error {
+	month, err := time.ParseInLocation(calendarMonthLayout, value, c.location)
+	if err != nil {
+		return nil
+	}
+	return c.Show(ctx, month)
+}
+
+func (c *Calendar) onDay(ctx *core.Context, value string) error {
+	day, err := time.ParseInLocation(calendarDayLayout, value, c.location)
+	if err != nil || !c.dayAvailable(day) {
+		return nil
+	}
+	if c.hourStep == 0 {
+		return c.selectTime(ctx, day)
+	}
+	markup, err := c.HourMarkup(day)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.EditText(c.text, c.messageOptions(markup)...)
+	return err
+}
+
+func (c *Calendar) onHour(ctx *core.Context, value string) error {
+	hour, err := time.ParseInLocation(calendarHourLayout, value, c.location)
+	if err != nil || !c.dayAvailable(c.dayOf(hour)) || !c.rangeAvailable(hour, hour.Add(time.Hour-time.Nanosecond)) {
+		return nil
+	}
+	if c.minuteStep <= 0 {
+		return c.selectTime(ctx, hour)
+	}
+	markup, err := c.MinuteMarkup(hour)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.EditText(c.text, c.messageOptions(markup)...)
+	return err
+}
+
+func (c *Calendar) onMinute(ctx *core.Context, value string) error {
+	minute, err := time.ParseInLocation(calendarMinuteLayout, value, c.location)
+	if err != nil || !c.dayAvailable(c.dayOf(minute)) || !c.rangeAvailable(minute, minute) {
+		return nil
+	}
+	return c.selectTime(ctx, minute)
+}
+
+func (c *Calendar) selectTime(ctx *core.Context, selected time.Time) error {
+	if c.storeKey != "" {
+		userManager := ctx.UserState()
+		if userManager == nil {
+			return ErrCalendarNoSender
+		}
+		err := userManager.SetDataValue(context.Background(), c.storeKey, selected.Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+	}
+	if c.onSelect != nil {
+		return c.onSelect(ctx, selected)
+	}
+	return nil
+}
+
+// CalendarTime reads a time stored by Calendar.StoreIn from state data
+func CalendarTime(data map[string]interface{}, key string) (time.Time, bool) {
+	switch v := data[key].(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
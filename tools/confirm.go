@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"github.com/erfjab/egobot/core"
+	"github.com/erfjab/egobot/models"
+)
+
+// ConfirmFunc receives the answer of a confirm dialog and the value it was started with
+type ConfirmFunc func(ctx *core.Context, confirmed bool, value string) error
+
+// confirmCallback is the packed callback payload of confirm buttons
+type confirmCallback struct {
+	CallbackData `prefix:"cf"`
+	Name         string
+	Answer       bool
+	Value        string
+}
+
+// Confirm is a Yes/No dialog. The value passed to Start travels in the
+// callback data, so no state is kept.
+//
+// Example:
+//
+//	deleteOrder := tools.NewConfirm("delete_order").
+//		OnAnswer(func(ctx *core.Context, confirmed bool, orderID string) error {
+//			if !confirmed {
+//				_, err := ctx.EditText("Kept.")
+//				return err
+//			}
+//			...
+//		})
+//	deleteOrder.Register(bot.RegisterCommands)
+//	deleteOrder.Start(ctx, "Delete order 42?", "42")
+type Confirm struct {
+	name      string
+	yesText   string
+	noText    string
+	parseMode string
+	onAnswer  ConfirmFunc
+}
+
+// NewConfirm creates a new confirm dialog. name must be unique among the
+// bot's confirm dialogs and must not contain ":".
+func NewConfirm(name string) *Confirm {
+	return &Confirm{
+		name:    name,
+		yesText: "Yes",
+		noText:  "No",
+	}
+}
+
+// Name returns the name of this dialog
+func (c *Confirm) Name() string {
+	return c.name
+}
+
+// Labels sets the Yes and No button labels
+func (c *Confirm) Labels(yes, no string) *Confirm {
+	c.yesText = yes
+	c.noText = no
+	return c
+}
+
+// ParseMode sets the parse mode of the question
+func (c *Confirm) ParseMode(parseMode string) *Confirm {
+	c.parseMode = parseMode
+	return c
+}
+
+// OnAnswer sets the handler receiving the answer
+func (c *Confirm) OnAnswer(handler ConfirmFunc) *Confirm {
+	c.onAnswer = handler
+	return c
+}
+
+// Register registers the callback handler for the Yes and No buttons
+func (c *Confirm) Register(r *core.RegisterCommands, opts ...interface{}) {
+	r.OnCallbackStruct(&confirmCallback{Name: c.name}, c.handleCallback, opts...)
+}
+
+// Start sends the question with Yes/No buttons as a new message.
+// value must fit into callback data and not contain ":".
+func (c *Confirm) Start(ctx *core.Context, question, value string) error {
+	markup, err := c.Markup(value)
+	if err != nil {
+		return err
+	}
+	opts := []core.MessageOption{core.WithReplyMarkup(markup)}
+	if c.parseMode != "" {
+		opts = append(opts, core.WithParseMode(c.parseMode))
+	}
+	_, err = ctx.Reply(question, opts...)
+	return err
+}
+
+// Markup builds the Yes/No keyboard for value
+func (c *Confirm) Markup(value string) (*models.InlineKeyboardMarkup, error) {
+	yes, err := CallbackButton(c.yesText, &confirmCallback{Name: c.name, Answer: true, Value: value})
+	if err != nil {
+		return nil, err
+	}
+	no, err := CallbackButton(c.noText, &confirmCallback{Name: c.name, Answer: false, Value: value})
+	if err != nil {
+		return nil, err
+	}
+	return NewInlineKeyboard().Row(yes, no).Build(), nil
+}
+
+func (c *Confirm) handleCallback(_ *core.Bot, _ *models.Update, ctx *core.Context) error {
+	payload, ok := core.GetCallbackStruct[*confirmCallback](ctx)
+	if !ok {
+		return nil
+	}
+	if c.onAnswer != nil {
+		if err := c.onAnswer(ctx, payload.Answer, payload.Value); err != nil {
+			return err
+		}
+	}
+	if !ctx.Answered() {
+		return ctx.Answer("")
+	}
+	return nil
+}
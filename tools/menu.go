@@ -0,0 +1,391 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/erfjab/egobot/core"
+	"github.com/erfjab/egobot/models"
+)
+
+var (
+	ErrMenuNoWindows      = errors.New("menu: no windows defined")
+	ErrMenuWindowNotFound = errors.New("menu: window not found")
+	ErrMenuNoSender       = errors.New("menu: update has no sender to keep state for")
+)
+
+// WindowTextFunc renders the text of a window from the user's state data
+type WindowTextFunc func(ctx *core.Context, data map[string]interface{}) (string, error)
+
+// WindowButtonsFunc renders the buttons of a window from the user's state data
+type WindowButtonsFunc func(ctx *core.Context, data map[string]interface{}) ([][]MenuButton, error)
+
+// MenuClickFunc handles a click on a menu button.
+// value is the MenuButton.Value of the clicked button.
+// Use menu.Open, menu.Back or menu.Refresh to update the message.
+type MenuClickFunc func(ctx *core.Context, menu *Menu, value string) error
+
+// MenuButton is a button of a menu window.
+// Exactly one of Navigate, Back, OnClick or URL should be set.
+type MenuButton struct {
+	Text     string
+	ID       string        // Identifies the button within its window (required with OnClick)
+	Value    string        // Optional payload passed to OnClick
+	Navigate string        // Window to open on click
+	Back     bool          // Go back to the previous window on click
+	OnClick  MenuClickFunc // Custom click handler
+	URL      string        // Opens a URL instead of sending a callback
+}
+
+// Window is a single screen of a Menu
+type Window struct {
+	ID        string
+	Text      WindowTextFunc
+	Buttons   WindowButtonsFunc
+	ParseMode string
+}
+
+// menuCallback is the packed callback payload of menu buttons
+type menuCallback struct {
+	CallbackData `prefix:"mn"`
+	Menu         string
+	Action       string
+	Window       string
+	Button       string
+	Value        string
+}
+
+const (
+	menuActionOpen  = "o"
+	menuActionBack  = "b"
+	menuActionClick = "c"
+)
+
+// Menu is a declarative inline menu whose windows are edited in place.
+//
+// Navigation between windows pushes onto a back stack kept in the user's
+// state data, and button clicks are routed through packed callback data.
+//
+// Example:
+//
+//	menu := tools.NewMenu("settings").
+//		Window("main", tools.StaticText("Settings"), tools.StaticButtons(
+//			[]tools.MenuButton{{Text: "Language", Navigate: "lang"}},
+//		)).
+//		Window("lang", tools.StaticText("Choose a language"), tools.StaticButtons(
+//			[]tools.MenuButton{{Text: "English", ID: "set", Value: "en", OnClick: setLanguage}},
+//			[]tools.MenuButton{{Text: "Back", Back: true}},
+//		))
+//	menu.Register(bot.RegisterCommands)
+//	bot.OnCommand("settings", func(bot *core.Bot, update *models.Update, ctx *core.Context) error {
+//		return menu.Start(ctx)
+//	})
+type Menu struct {
+	name    string
+	root    string
+	windows map[string]*Window
+}
+
+// NewMenu creates a new menu. name must be unique among the bot's menus
+// and must not contain ":".
+func NewMenu(name string) *Menu {
+	return &Menu{
+		name:    name,
+		windows: make(map[string]*Window),
+	}
+}
+
+// Name returns the name of this menu
+func (m *Menu) Name() string {
+	return m.name
+}
+
+// Window adds a window. The first window added is the root window.
+func (m *Menu) Window(id string, text WindowTextFunc, buttons WindowButtonsFunc) *Menu {
+	return m.AddWindow(&Window{ID: id, Text: text, Buttons: buttons})
+}
+
+// AddWindow adds a fully configured window. The first window added is the root window.
+func (m *Menu) AddWindow(window *Window) *Menu {
+	if m.root == "" {
+		m.root = window.ID
+	}
+	m.windows[window.ID] = window
+	return m
+}
+
+// Register registers the callback handler routing all clicks of this menu
+func (m *Menu) Register(r *core.RegisterCommands, opts ...interface{}) {
+	r.OnCallbackStruct(&menuCallback{Menu: m.name}, m.handleCallback, opts...)
+}
+
+// Start sends the root window as a new message and resets the back stack
+func (m *Menu) Start(ctx *core.Context) error {
+	if m.root == "" {
+		return ErrMenuNoWindows
+	}
+	if err := m.setStack(ctx, []string{m.root}); err != nil {
+		return err
+	}
+	return m.render(ctx, m.root, false)
+}
+
+// Open navigates to a window, pushing it onto the back stack
+func (m *Menu) Open(ctx *core.Context, windowID string) error {
+	if _, ok := m.windows[windowID]; !ok {
+		return fmt.Errorf("%w: %s", ErrMenuWindowNotFound, windowID)
+	}
+	_, err := m.updateStack(ctx, func(stack []string) []string {
+		if len(stack) == 0 || stack[len(stack)-1] != windowID {
+			stack = append(stack, windowID)
+		}
+		return stack
+	})
+	if err != nil {
+		return err
+	}
+	return m.render(ctx, windowID, true)
+}
+
+// Back returns to the previous window. At the root window it re-renders the root.
+func (m *Menu) Back(ctx *core.Context) error {
+	stack, err := m.updateStack(ctx, func(stack []string) []string {
+		if len(stack) > 1 {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			stack = []string{m.root}
+		}
+		return stack
+	})
+	if err != nil {
+		return err
+	}
+	return m.render(ctx, stack[len(stack)-1], true)
+}
+
+// Refresh re-renders the current window, e.g. after the user's data changed
+func (m *Menu) Refresh(ctx *core.Context) error {
+	current, err := m.Current(ctx)
+	if err != nil {
+		return err
+	}
+	return m.render(ctx, current, true)
+}
+
+// Current returns the ID of the window currently shown to the user
+func (m *Menu) Current(ctx *core.Context) (string, error) {
+	stack, err := m.stack(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(stack) == 0 {
+		return m.root, nil
+	}
+	return stack[len(stack)-1], nil
+}
+
+func (m *Menu) stackKey() string {
+	return "menu:" + m.name + ":stack"
+}
+
+func (m *Menu) stack(ctx *core.Context) ([]string, error) {
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return nil, ErrMenuNoSender
+	}
+	value, err := userManager.GetDataValue(context.Background(), m.stackKey())
+	if err != nil {
+		return nil, err
+	}
+	return toStringSlice(value), nil
+}
+
+func (m *Menu) setStack(ctx *core.Context, stack []string) error {
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return ErrMenuNoSender
+	}
+	return userManager.SetDataValue(context.Background(), m.stackKey(), stack)
+}
+
+// updateStack changes the back stack atomically and returns the new stack
+func (m *Menu) updateStack(ctx *core.Context, fn func(stack []string) []string) ([]string, error) {
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return nil, ErrMenuNoSender
+	}
+	var stack []string
+	err := userManager.UpdateData(context.Background(), func(data map[string]interface{}) error {
+		stack = fn(toStringSlice(data[m.stackKey()]))
+		data[m.stackKey()] = stack
+		return nil
+	})
+	return stack, err
+}
+
+func (m *Menu) userData(ctx *core.Context) (map[string]interface{}, error) {
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return map[string]interface{}{}, nil
+	}
+	return userManager.GetData(context.Background())
+}
+
+// render draws a window, editing the current message when edit is true
+// and the update allows it, otherwise sending a new message
+func (m *Menu) render(ctx *core.Context, windowID string, edit bool) error {
+	window, ok := m.windows[windowID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrMenuWindowNotFound, windowID)
+	}
+	data, err := m.userData(ctx)
+	if err != nil {
+		return err
+	}
+
+	text := ""
+	if window.Text != nil {
+		if text, err = window.Text(ctx, data); err != nil {
+			return err
+		}
+	}
+	markup, err := m.keyboard(ctx, window, data)
+	if err != nil {
+		return err
+	}
+
+	opts := []core.MessageOption{core.WithReplyMarkup(markup)}
+	if window.ParseMode != "" {
+		opts = append(opts, core.WithParseMode(window.ParseMode))
+	}
+	if edit && ctx.Update() != nil && ctx.Update().CallbackQuery != nil {
+		_, err = ctx.EditText(text, opts...)
+		return err
+	}
+	_, err = ctx.Reply(text, opts...)
+	return err
+}
+
+func (m *Menu) keyboard(ctx *core.Context, window *Window, data map[string]interface{}) (*models.InlineKeyboardMarkup, error) {
+	kb := NewInlineKeyboard()
+	if window.Buttons == nil {
+		return kb.Build(), nil
+	}
+	rows, err := window.Buttons(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		buttons := make([]models.InlineKeyboardButton, 0, len(row))
+		for _, button := range row {
+			btn, err := m.button(window, button)
+			if err != nil {
+				return nil, err
+			}
+			buttons = append(buttons, btn)
+		}
+		kb.Row(buttons...)
+	}
+	return kb.Build(), nil
+}
+
+func (m *Menu) button(window *Window, button MenuButton) (models.InlineKeyboardButton, error) {
+	if button.URL != "" {
+		return URLButton(button.Text, button.URL), nil
+	}
+	payload := &menuCallback{Menu: m.name, Window: window.ID}
+	switch {
+	case button.Back:
+		payload.Action = menuActionBack
+	case button.Navigate != "":
+		payload.Action = menuActionOpen
+		payload.Window = button.Navigate
+	default:
+		payload.Action = menuActionClick
+		payload.Button = button.ID
+		payload.Value = button.Value
+	}
+	return CallbackButton(button.Text, payload)
+}
+
+func (m *Menu) handleCallback(_ *core.Bot, _ *models.Update, ctx *core.Context) error {
+	payload, ok := core.GetCallbackStruct[*menuCallback](ctx)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	switch payload.Action {
+	case menuActionOpen:
+		err = m.Open(ctx, payload.Window)
+	case menuActionBack:
+		err = m.Back(ctx)
+	case menuActionClick:
+		err = m.click(ctx, payload)
+	}
+	if err != nil {
+		return err
+	}
+	if !ctx.Answered() {
+		return ctx.Answer("")
+	}
+	return nil
+}
+
+func (m *Menu) click(ctx *core.Context, payload *menuCallback) error {
+	window, ok := m.windows[payload.Window]
+	if !ok || window.Buttons == nil {
+		return nil
+	}
+	data, err := m.userData(ctx)
+	if err != nil {
+		return err
+	}
+	rows, err := window.Buttons(ctx, data)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		for _, button := range row {
+			if button.ID != payload.Button || button.Value != payload.Value || button.OnClick == nil {
+				continue
+			}
+			return button.OnClick(ctx, m, payload.Value)
+		}
+	}
+	return nil
+}
+
+// StaticText returns a WindowTextFunc that always renders text
+func StaticText(text string) WindowTextFunc {
+	return func(*core.Context, map[string]interface{}) (string, error) {
+		return text, nil
+	}
+}
+
+// StaticButtons returns a WindowButtonsFunc that always renders rows
+func StaticButtons(rows ...[]MenuButton) WindowButtonsFunc {
+	return func(*core.Context, map[string]interface{}) ([][]MenuButton, error) {
+		return rows, nil
+	}
+}
+
+// toStringSlice converts a []string stored in state data, which becomes
+// []interface{} after a JSON round-trip in persistent storages
+func toStringSlice(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return append([]string(nil), v...)
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package tools
+
+import (
+	"context"
+	"errors"
+
+	"github.com/erfjab/egobot/core"
+	"github.com/erfjab/egobot/models"
+)
+
+var ErrMultiSelectNoSender = errors.New("multiselect: update has no sender to keep the selection for")
+
+// SelectOption is a selectable option of a MultiSelect
+type SelectOption struct {
+	Text  string
+	Value string // Must fit into callback data and not contain ":"
+}
+
+// MultiSelectDoneFunc receives the selected values, in option order
+type MultiSelectDoneFunc func(ctx *core.Context, selected []string) error
+
+// multiSelectCallback is the packed callback payload of multi-select buttons
+type multiSelectCallback struct {
+	CallbackData `prefix:"ms"`
+	Name         string
+	Action       string
+	Value        string
+}
+
+const (
+	multiSelectActionToggle = "t"
+	multiSelectActionDone   = "d"
+)
+
+// MultiSelect is a checkbox keyboard: clicking an option toggles its mark and
+// the Done button delivers the selection. The selection is kept in the user's
+// state data while the keyboard is open.
+//
+// Example:
+//
+//	tags := tools.NewMultiSelect("tags",
+//		tools.SelectOption{Text: "Go", Value: "go"},
+//		tools.SelectOption{Text: "Rust", Value: "rust"},
+//	).OnDone(func(ctx *core.Context, selected []string) error {
+//		_, err := ctx.EditText("Selected: " + strings.Join(selected, ", "))
+//		return err
+//	})
+//	tags.Register(bot.RegisterCommands)
+type MultiSelect struct {
+	name      string
+	options   []SelectOption
+	columns   int
+	checked   string
+	unchecked string
+	doneText  string
+	text      string
+	parseMode string
+	min       int
+	minAlert  string
+	onDone    MultiSelectDoneFunc
+}
+
+// NewMultiSelect creates a new multi-select. name must be unique among the
+// bot's multi-selects and must not contain ":".
+func NewMultiSelect(name string, options ...SelectOption) *MultiSelect {
+	return &MultiSelect{
+		name:      name,
+		options:   options,
+		columns:   1,
+		checked:   "✅",
+		unchecked: "⬜",
+		doneText:  "Done",
+	}
+}
+
+// Name returns the name of this multi-select
+func (s *MultiSelect) Name() string {
+	return s.name
+}
+
+// Columns sets the number of options per row (default 1)
+func (s *MultiSelect) Columns(columns int) *MultiSelect {
+	if columns > 0 {
+		s.columns = columns
+	}
+	return s
+}
+
+// Marks sets the checked and unchecked marks prepended to option labels
+func (s *MultiSelect) Marks(checked, unchecked string) *MultiSelect {
+	s.checked = checked
+	s.unchecked = unchecked
+	return s
+}
+
+// DoneText sets the label of the Done button
+func (s *MultiSelect) DoneText(text string) *MultiSelect {
+	s.doneText = text
+	return s
+}
+
+// Text sets the message text sent by Start
+func (s *MultiSelect) Text(text string) *MultiSelect {
+	s.text = text
+	return s
+}
+
+// ParseMode sets the parse mode of the message text
+func (s *MultiSelect) ParseMode(parseMode string) *MultiSelect {
+	s.parseMode = parseMode
+	return s
+}
+
+// MinSelected requires at least n options before Done is accepted,
+// answering the callback with alert otherwise
+func (s *MultiSelect) MinSelected(n int, alert string) *MultiSelect {
+	s.min = n
+	s.minAlert = alert
+	return s
+}
+
+// OnDone sets the handler receiving the selected values
+func (s *MultiSelect) OnDone(handler MultiSelectDoneFunc) *MultiSelect {
+	s.onDone = handler
+	return s
+}
+
+// Register registers the callback handler for toggles and Done
+func (s *MultiSelect) Register(r *core.RegisterCommands, opts ...interface{}) {
+	r.OnCallbackStruct(&multiSelectCallback{Name: s.name}, s.handleCallback, opts...)
+}
+
+// Start sends the keyboard as a new message with preselected values checked
+func (s *MultiSelect) Start(ctx *core.Context, preselected ...string) error {
+	markup, err := s.Markup(preselected)
+	if err != nil {
+		return err
+	}
+	if err := s.setSelected(ctx, preselected); err != nil {
+		return err
+	}
+	opts := []core.MessageOption{core.WithReplyMarkup(markup)}
+	if s.parseMode != "" {
+		opts = append(opts, core.WithParseMode(s.parseMode))
+	}
+	_, err = ctx.Reply(s.text, opts...)
+	return err
+}
+
+// Markup builds the keyboard for the given selection
+func (s *MultiSelect) Markup(selected []string) (*models.InlineKeyboardMarkup, error) {
+	set := make(map[string]bool, len(selected))
+	for _, value := range selected {
+		set[value] = true
+	}
+
+	kb := NewInlineKeyboard()
+	var row []models.InlineKeyboardButton
+	for _, option := range s.options {
+		mark := s.unchecked
+		if set[option.Value] {
+			mark = s.checked
+		}
+		button, err := CallbackButton(mark+" "+option.Text, &multiSelectCallback{
+			Name:   s.name,
+			Action: multiSelectActionToggle,
+			Value:  option.Value,
+		})
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, button)
+		if len(row) == s.columns {
+			kb.Row(row...)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		kb.Row(row...)
+	}
+	done, err := CallbackButton(s.doneText, &multiSelectCallback{Name: s.name, Action: multiSelectActionDone})
+	if err != nil {
+		return nil, err
+	}
+	kb.Row(done)
+	return kb.Build(), nil
+}
+
+// Selected returns the current selection of the user, in option order
+func (s *MultiSelect) Selected(ctx *core.Context) ([]string, error) {
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return nil, ErrMultiSelectNoSender
+	}
+	value, err := userManager.GetDataValue(context.Background(), s.dataKey())
+	if err != nil {
+		return nil, err
+	}
+	return s.ordered(toStringSlice(value)), nil
+}
+
+func (s *MultiSelect) dataKey() string {
+	return "multiselect:" + s.name
+}
+
+func (s *MultiSelect) setSelected(ctx *core.Context, selected []string) error {
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return ErrMultiSelectNoSender
+	}
+	if selected == nil {
+		selected = []string{}
+	}
+	return userManager.SetDataValue(context.Background(), s.dataKey(), selected)
+}
+
+// ordered drops unknown values and sorts the rest in option order
+func (s *MultiSelect) ordered(selected []string) []string {
+	set := make(map[string]bool, len(selected))
+	for _, value := range selected {
+		set[value] = true
+	}
+	result := make([]string, 0, len(selected))
+	for _, option := range s.options {
+		if set[option.Value] {
+			result = append(result, option.Value)
+		}
+	}
+	return result
+}
+
+func (s *MultiSelect) handleCallback(_ *core.Bot, _ *models.Update, ctx *core.Context) error {
+	payload, ok := core.GetCallbackStruct[*multiSelectCallback](ctx)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	switch payload.Action {
+	case multiSelectActionToggle:
+		err = s.toggle(ctx, payload.Value)
+	case multiSelectActionDone:
+		err = s.done(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if !ctx.Answered() {
+		return ctx.Answer("")
+	}
+	return nil
+}
+
+func (s *MultiSelect) toggle(ctx *core.Context, value string) error {
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return ErrMultiSelectNoSender
+	}
+
+	// Toggle atomically so quick taps on several options don't lose each other
+	var next []string
+	err := userManager.UpdateData(context.Background(), func(data map[string]interface{}) error {
+		selected := toStringSlice(data[s.dataKey()])
+		next = make([]string, 0, len(selected)+1)
+		found := false
+		for _, v := range selected {
+			if v == value {
+				found = true
+				continue
+			}
+			next = append(next, v)
+		}
+		if !found {
+			next = append(next, value)
+		}
+		next = s.ordered(next)
+		data[s.dataKey()] = next
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	markup, err := s.Markup(next)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.EditMarkup(markup)
+	return err
+}
+
+func (s *MultiSelect) done(ctx *core.Context) error {
+	userManager := ctx.UserState()
+	if userManager == nil {
+		return ErrMultiSelectNoSender
+	}
+
+	// Take the selection and drop its key in one step, so a double tap on
+	// Done delivers it once: Start always sets the key, so a missing key
+	// means the selection was already delivered
+	var selected []string
+	tooFew, finished := false, false
+	err := userManager.UpdateData(context.Background(), func(data map[string]interface{}) error {
+		value, ok := data[s.dataKey()]
+		if !ok {
+			finished = true
+			return nil
+		}
+		selected = s.ordered(toStringSlice(value))
+		if len(selected) < s.min {
+			tooFew = true
+			return nil
+		}
+		delete(data, s.dataKey())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if finished {
+		return ctx.Answer("")
+	}
+	if tooFew {
+		return ctx.AnswerAlert(s.minAlert)
+	}
+	if s.onDone != nil {
+		return s.onDone(ctx, selected)
+	}
+	return nil
+}
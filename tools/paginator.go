@@ -0,0 +1,351 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/erfjab/egobot/core"
+	"github.com/erfjab/egobot/models"
+)
+
+var ErrPaginatorNoSource = errors.New("paginator: no data source")
+
+// PageItem is a single item of a paginated list
+type PageItem struct {
+	Text  string
+	Value string // Passed to the select handler, must fit into callback data
+}
+
+// PageSource returns the items of a page (zero-based) and the total number of items
+type PageSource func(ctx *core.Context, page, pageSize int) ([]PageItem, int, error)
+
+// PageSelectFunc handles a click on a paginated item
+type PageSelectFunc func(ctx *core.Context, item PageItem) error
+
+// PageTextFunc renders the message text of a page.
+// page is zero-based, pages is the total number of pages.
+type PageTextFunc func(ctx *core.Context, page, pages int) (string, error)
+
+// SliceSource returns a PageSource over a fixed slice of items
+func SliceSource(items []PageItem) PageSource {
+	return func(_ *core.Context, page, pageSize int) ([]PageItem, int, error) {
+		start := page * pageSize
+		if start > len(items) {
+			start = len(items)
+		}
+		end := start + pageSize
+		if end > len(items) {
+			end = len(items)
+		}
+		return items[start:end], len(items), nil
+	}
+}
+
+// paginatorCallback is the packed callback payload of paginator buttons
+type paginatorCallback struct {
+	CallbackData `prefix:"pg"`
+	Name         string
+	Action       string
+	Page         int
+	Value        string
+}
+
+const (
+	paginatorActionPage   = "p"
+	paginatorActionSelect = "s"
+	paginatorActionNoop   = "n"
+)
+
+// Paginator renders a list as pages of inline buttons with prev/next navigation.
+// The page number travels in the callback data, so no state is kept.
+//
+// Example:
+//
+//	users := tools.NewPaginator("users", loadUsers).
+//		PageSize(8).
+//		Columns(2).
+//		OnSelect(func(ctx *core.Context, item tools.PageItem) error {
+//			_, err := ctx.Reply("Selected " + item.Text)
+//			return err
+//		})
+//	users.Register(bot.RegisterCommands)
+//	bot.OnCommand("users", func(bot *core.Bot, update *models.Update, ctx *core.Context) error {
+//		return users.Start(ctx)
+//	})
+type Paginator struct {
+	name      string
+	source    PageSource
+	pageSize  int
+	columns   int
+	prevText  string
+	nextText  string
+	indicator string
+	hideEmpty bool
+	text      PageTextFunc
+	parseMode string
+	onSelect  PageSelectFunc
+	extraRows [][]models.InlineKeyboardButton
+}
+
+// NewPaginator creates a new paginator. name must be unique among the bot's
+// paginators and must not contain ":".
+func NewPaginator(name string, source PageSource) *Paginator {
+	return &Paginator{
+		name:      name,
+		source:    source,
+		pageSize:  10,
+		columns:   1,
+		prevText:  "«",
+		nextText:  "»",
+		indicator: "%d/%d",
+	}
+}
+
+// Name returns the name of this paginator
+func (p *Paginator) Name() string {
+	return p.name
+}
+
+// PageSize sets the number of items per page (default 10)
+func (p *Paginator) PageSize(size int) *Paginator {
+	if size > 0 {
+		p.pageSize = size
+	}
+	return p
+}
+
+// Columns sets the number of item buttons per row (default 1)
+func (p *Paginator) Columns(columns int) *Paginator {
+	if columns > 0 {
+		p.columns = columns
+	}
+	return p
+}
+
+// Labels sets the prev and next button labels
+func (p *Paginator) Labels(prev, next string) *Paginator {
+	p.prevText = prev
+	p.nextText = next
+	return p
+}
+
+// Indicator sets the format of the page indicator button, receiving the
+// one-based page and the page count. Pass "" to hide the indicator.
+func (p *Paginator) Indicator(format string) *Paginator {
+	p.indicator = format
+	return p
+}
+
+// HideUnavailable hides prev/next buttons instead of rendering them as no-ops
+// on the first and last page
+func (p *Paginator) HideUnavailable() *Paginator {
+	p.hideEmpty = true
+	return p
+}
+
+// Text sets the message text of each page
+func (p *Paginator) Text(text PageTextFunc) *Paginator {
+	p.text = text
+	return p
+}
+
+// ParseMode sets the parse mode of the page text
+func (p *Paginator) ParseMode(parseMode string) *Paginator {
+	p.parseMode = parseMode
+	return p
+}
+
+// OnSelect sets the handler called when an item is clicked
+func (p *Paginator) OnSelect(handler PageSelectFunc) *Paginator {
+	p.onSelect = handler
+	return p
+}
+
+// ExtraRow appends a row of buttons below the navigation row, e.g. a close button
+func (p *Paginator) ExtraRow(buttons ...models.InlineKeyboardButton) *Paginator {
+	p.extraRows = append(p.extraRows, buttons)
+	return p
+}
+
+// Register registers the callback handler for page changes and item clicks
+func (p *Paginator) Register(r *core.RegisterCommands, opts ...interface{}) {
+	r.OnCallbackStruct(&paginatorCallback{Name: p.name}, p.handleCallback, opts...)
+}
+
+// Start sends the first page as a new message
+func (p *Paginator) Start(ctx *core.Context) error {
+	text, markup, err := p.Render(ctx, 0)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.Reply(text, p.messageOptions(markup)...)
+	return err
+}
+
+// Show edits the current message to show a page
+func (p *Paginator) Show(ctx *core.Context, page int) error {
+	text, markup, err := p.Render(ctx, page)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.EditText(text, p.messageOptions(markup)...)
+	return err
+}
+
+// Render builds the text and keyboard of a page (zero-based).
+// Out-of-range pages are clamped.
+func (p *Paginator) Render(ctx *core.Context, page int) (string, *models.InlineKeyboardMarkup, error) {
+	if p.source == nil {
+		return "", nil, ErrPaginatorNoSource
+	}
+	if page < 0 {
+		page = 0
+	}
+
+	items, total, err := p.source(ctx, page, p.pageSize)
+	if err != nil {
+		return "", nil, err
+	}
+	pages := p.pageCount(total)
+	if page >= pages {
+		page = pages - 1
+		if items, total, err = p.source(ctx, page, p.pageSize); err != nil {
+			return "", nil, err
+		}
+		pages = p.pageCount(total)
+	}
+
+	text := ""
+	if p.text != nil {
+		if text, err = p.text(ctx, page, pages); err != nil {
+			return "", nil, err
+		}
+	}
+
+	kb := NewInlineKeyboard()
+	for start := 0; start < len(items); start += p.columns {
+		end := start + p.columns
+		if end > len(items) {
+			end = len(items)
+		}
+		row := make([]models.InlineKeyboardButton, 0, end-start)
+		for _, item := range items[start:end] {
+			btn, err := CallbackButton(item.Text, &paginatorCallback{
+				Name:   p.name,
+				Action: paginatorActionSelect,
+				Page:   page,
+				Value:  item.Value,
+			})
+			if err != nil {
+				return "", nil, err
+			}
+			row = append(row, btn)
+		}
+		kb.Row(row...)
+	}
+
+	if pages > 1 {
+		nav, err := p.navigation(page, pages)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(nav) > 0 {
+			kb.Row(nav...)
+		}
+	}
+	for _, row := range p.extraRows {
+		kb.Row(row...)
+	}
+	return text, kb.Build(), nil
+}
+
+func (p *Paginator) pageCount(total int) int {
+	if total <= 0 {
+		return 1
+	}
+	return (total + p.pageSize - 1) / p.pageSize
+}
+
+func (p *Paginator) navigation(page, pages int) ([]models.InlineKeyboardButton, error) {
+	var nav []models.InlineKeyboardButton
+
+	add := func(text, action string, target int) error {
+		btn, err := CallbackButton(text, &paginatorCallback{Name: p.name, Action: action, Page: target})
+		if err != nil {
+			return err
+		}
+		nav = append(nav, btn)
+		return nil
+	}
+
+	if page > 0 {
+		if err := add(p.prevText, paginatorActionPage, page-1); err != nil {
+			return nil, err
+		}
+	} else if !p.hideEmpty {
+		if err := add(" ", paginatorActionNoop, page); err != nil {
+			return nil, err
+		}
+	}
+	if p.indicator != "" {
+		if err := add(fmt.Sprintf(p.indicator, page+1, pages), paginatorActionNoop, page); err != nil {
+			return nil, err
+		}
+	}
+	if page < pages-1 {
+		if err := add(p.nextText, paginatorActionPage, page+1); err != nil {
+			return nil, err
+		}
+	} else if !p.hideEmpty {
+		if err := add(" ", paginatorActionNoop, page); err != nil {
+			return nil, err
+		}
+	}
+	return nav, nil
+}
+
+func (p *Paginator) messageOptions(markup *models.InlineKeyboardMarkup) []core.MessageOption {
+	opts := []core.MessageOption{core.WithReplyMarkup(markup)}
+	if p.parseMode != "" {
+		opts = append(opts, core.WithParseMode(p.parseMode))
+	}
+	return opts
+}
+
+func (p *Paginator) handleCallback(_ *core.Bot, _ *models.Update, ctx *core.Context) error {
+	payload, ok := core.GetCallbackStruct[*paginatorCallback](ctx)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	switch payload.Action {
+	case paginatorActionPage:
+		err = p.Show(ctx, payload.Page)
+	case paginatorActionSelect:
+		err = p.selectItem(ctx, payload)
+	}
+	if err != nil {
+		return err
+	}
+	if !ctx.Answered() {
+		return ctx.Answer("")
+	}
+	return nil
+}
+
+func (p *Paginator) selectItem(ctx *core.Context, payload *paginatorCallback) error {
+	if p.onSelect == nil {
+		return nil
+	}
+	item := PageItem{Value: payload.Value}
+	if items, _, err := p.source(ctx, payload.Page, p.pageSize); err == nil {
+		for _, candidate := range items {
+			if candidate.Value == payload.Value {
+				item = candidate
+				break
+			}
+		}
+	}
+	return p.onSelect(ctx, item)
+}